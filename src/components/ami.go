@@ -0,0 +1,135 @@
+package components
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// AMI selection strategies for AmiSettings.Strategy.
+const (
+	// AmiStrategyLookup takes the most recent image of Owners whose name
+	// matches Name, which may hold * and ? wildcards, and that carries Tags.
+	AmiStrategyLookup = "lookup"
+	// AmiStrategyId pins the image Id.
+	AmiStrategyId = "id"
+	// AmiStrategySsm reads the image ID from the SSM parameter SsmParameter,
+	// e.g. one a build pipeline publishes.
+	AmiStrategySsm = "ssm"
+)
+
+// AmiStrategies lists the supported AmiSettings.Strategy values.
+var AmiStrategies = []string{AmiStrategyLookup, AmiStrategyId, AmiStrategySsm}
+
+// DefaultAmiOwners restricts lookups by name to images of the stack's own
+// account, so a public image with the same name is never picked. Pinned
+// images, by ID or SSM parameter, are accepted from any owner unless Owners
+// is set.
+var DefaultAmiOwners = []string{"self"}
+
+// AmiSettings picks the AMI the app instances run.
+type AmiSettings struct {
+	Strategy string `json:"strategy"`
+	// Owners are account IDs or aliases like "self" or "amazon" whose images
+	// a lookup considers.
+	Owners []string          `json:"owners"`
+	Name   string            `json:"name"`
+	Tags   map[string]string `json:"tags"`
+	// Id is the image for AmiStrategyId.
+	Id string `json:"id"`
+	// SsmParameter is the parameter path for AmiStrategySsm.
+	SsmParameter string `json:"ssmParameter"`
+	// MaxAgeDays fails the deployment when the image is older; 0 allows any age.
+	MaxAgeDays int `json:"maxAgeDays"`
+}
+
+// WithDefaults fills in the settings left unset.
+func (s AmiSettings) WithDefaults() AmiSettings {
+	if s.Strategy == "" {
+		s.Strategy = AmiStrategyLookup
+	}
+	if len(s.Owners) == 0 && s.Strategy == AmiStrategyLookup {
+		s.Owners = DefaultAmiOwners
+	}
+	return s
+}
+
+// Ami is the image an AmiSettings resolved to.
+type Ami struct {
+	Id   string
+	Name string
+	// CreationDate is the image's creation time in RFC 3339 format.
+	CreationDate string
+}
+
+// Age returns how long before now the image was created.
+func (a Ami) Age(now time.Time) (time.Duration, error) {
+	created, err := time.Parse(time.RFC3339, a.CreationDate)
+	if err != nil {
+		return 0, fmt.Errorf("AMI %s has an invalid creation date %q: %w", a.Id, a.CreationDate, err)
+	}
+	return now.Sub(created), nil
+}
+
+// ResolveAmi finds the image s selects and checks its age against
+// s.MaxAgeDays.
+func ResolveAmi(ctx *pulumi.Context, s AmiSettings) (Ami, error) {
+	s = s.WithDefaults()
+	args := &ec2.LookupAmiArgs{
+		MostRecent: pulumi.BoolRef(true),
+		Owners:     s.Owners,
+	}
+	var source string
+	switch s.Strategy {
+	case AmiStrategyLookup:
+		source = fmt.Sprintf("named %q", s.Name)
+		args.Filters = append(args.Filters, ec2.GetAmiFilter{Name: "name", Values: []string{s.Name}})
+		var keys []string
+		for key := range s.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			args.Filters = append(args.Filters, ec2.GetAmiFilter{Name: "tag:" + key, Values: []string{s.Tags[key]}})
+		}
+	case AmiStrategyId:
+		source = s.Id
+		args.Filters = append(args.Filters, ec2.GetAmiFilter{Name: "image-id", Values: []string{s.Id}})
+	case AmiStrategySsm:
+		parameter, err := ssm.LookupParameter(ctx, &ssm.LookupParameterArgs{
+			Name: s.SsmParameter,
+		})
+		if err != nil {
+			return Ami{}, fmt.Errorf("reading AMI ID from SSM parameter %s: %w", s.SsmParameter, err)
+		}
+		source = fmt.Sprintf("%s from SSM parameter %s", parameter.Value, s.SsmParameter)
+		args.Filters = append(args.Filters, ec2.GetAmiFilter{Name: "image-id", Values: []string{parameter.Value}})
+	default:
+		return Ami{}, fmt.Errorf("unknown AMI strategy %q", s.Strategy)
+	}
+
+	if len(s.Owners) > 0 {
+		source += fmt.Sprintf(" owned by %v", s.Owners)
+	}
+	image, err := ec2.LookupAmi(ctx, args)
+	if err != nil {
+		return Ami{}, fmt.Errorf("looking up AMI %s: %w", source, err)
+	}
+	ami := Ami{Id: image.Id, Name: image.Name, CreationDate: image.CreationDate}
+
+	if s.MaxAgeDays > 0 {
+		age, err := ami.Age(time.Now())
+		if err != nil {
+			return Ami{}, err
+		}
+		if maxAge := time.Duration(s.MaxAgeDays) * 24 * time.Hour; age > maxAge {
+			return Ami{}, fmt.Errorf("AMI %s (%s) was created %s, %d days ago, more than the %d days maxAgeDays allows",
+				ami.Id, ami.Name, ami.CreationDate, int(age.Hours()/24), s.MaxAgeDays)
+		}
+	}
+	return ami, nil
+}
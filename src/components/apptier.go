@@ -0,0 +1,351 @@
+package components
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/autoscaling"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// AppTierArgs configures the autoscaled web application instances.
+type AppTierArgs struct {
+	// NamePrefix prefixes the child resource names; see naming.
+	NamePrefix string
+
+	// AmiId is an image in the app tier's region.
+	AmiId        pulumi.StringInput
+	InstanceType string
+	SSHKeyName   string
+
+	// MinSize, MaxSize and DesiredCapacity bound the autoscaling group.
+	MinSize         int
+	MaxSize         int
+	DesiredCapacity int
+	// MixedInstances, when set, spreads the group over several instance
+	// types and spot capacity instead of InstanceType alone.
+	MixedInstances *MixedInstancesSettings
+	// InstanceRefresh replaces the instances whenever the launch template
+	// changes.
+	InstanceRefresh InstanceRefreshSettings
+
+	SubnetIDs       []pulumi.IDOutput
+	SecurityGroupId pulumi.IDOutput
+	// PrivateInstances keeps instances off the internet; SubnetIDs must then
+	// be private subnets with egress through NAT or VPC endpoints.
+	PrivateInstances bool
+	// TargetGroupArn is the load balancer target group the instances join.
+	TargetGroupArn pulumi.StringOutput
+	// ServiceTargetGroupArns are further target groups, by service name, for
+	// the other services the instances run.
+	ServiceTargetGroupArns map[string]pulumi.StringOutput
+
+	// Scaling picks the scaling policy and scheduled actions.
+	Scaling ScalingSettings
+	// RequestCountResourceLabel identifies the load balancer and target
+	// group for ScalingPolicyTargetRequests, as "<alb suffix>/<tg suffix>".
+	RequestCountResourceLabel pulumi.StringInput
+
+	// Colors, when set, run one group per blue/green color instead of a
+	// single group on AmiId behind TargetGroupArn.
+	Colors []ColorGroup
+
+	// The database connection, topic, region and log group are written to
+	// the app's config at boot.
+	DbAddress    pulumi.StringOutput
+	DbPort       pulumi.IntOutput
+	DbName       pulumi.StringOutput
+	DbUsername   pulumi.StringOutput
+	TopicArn     pulumi.StringOutput
+	TopicRegion  string
+	Region       string
+	LogGroupName string
+	// DbSecretArn is the Secrets Manager secret the instances read the
+	// database password from at boot; the instance role may read only it.
+	DbSecretArn pulumi.StringOutput
+}
+
+// AppTier is an autoscaling group of application instances, scaled by
+// policy and registered with the load balancer's target groups.
+type AppTier struct {
+	pulumi.ResourceState
+	naming
+
+	// AutoScalingGroupName is the only group, or the active color's.
+	AutoScalingGroupName pulumi.StringOutput
+	// AutoScalingGroupNames holds each blue/green color's group by color.
+	AutoScalingGroupNames map[string]pulumi.StringOutput
+}
+
+// NewAppTier creates the instance role, launch template, autoscaling group
+// and its scaling policies.
+func NewAppTier(ctx *pulumi.Context, name string, args *AppTierArgs, opts ...pulumi.ResourceOption) (*AppTier, error) {
+	component := &AppTier{naming: naming{args.NamePrefix}}
+	err := ctx.RegisterComponentResource(typePrefix+"AppTier", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	userData := pulumi.All(args.DbAddress, args.DbPort, args.DbName, args.DbUsername, args.DbSecretArn,
+		args.TopicArn).ApplyT(func(values []interface{}) (string, error) {
+		userData, err := RenderUserData(AppSettings{
+			DbHost:      values[0].(string),
+			DbPort:      values[1].(int),
+			DbName:      values[2].(string),
+			DbUser:      values[3].(string),
+			DbSecretArn: values[4].(string),
+			SnsTopicArn: values[5].(string),
+			SnsRegion:   args.TopicRegion,
+			Region:      args.Region,
+			LogGroup:    args.LogGroupName,
+		})
+		if err != nil {
+			return "", fmt.Errorf("app tier %s: %w", name, err)
+		}
+		return base64.StdEncoding.EncodeToString([]byte(userData)), nil
+	}).(pulumi.StringOutput)
+
+	// Create IAM Role
+	role, err := iam.NewRole(ctx, component.childName("role"), &iam.RoleArgs{
+		AssumeRolePolicy: pulumi.String(`{
+				"Version": "2012-10-17",
+				"Statement": [
+					{
+						"Action": "sts:AssumeRole",
+						"Principal": {
+							"Service": "ec2.amazonaws.com"
+						},
+						"Effect": "Allow",
+						"Sid": ""
+					}
+				]
+			}`),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+	// Attach 'CloudWatchAgentServerPolicy' to the IAM Role
+	_, err = iam.NewRolePolicyAttachment(ctx, component.childName("rolePolicyAttachment"), &iam.RolePolicyAttachmentArgs{
+		Role:      role.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/CloudWatchAgentServerPolicy"),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	//Attach Lambda Access Policy
+	_, err = iam.NewRolePolicyAttachment(ctx, component.childName("rolePolicyAttachment-LambdaFullAccess"), &iam.RolePolicyAttachmentArgs{
+		Role:      role.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AWSLambda_FullAccess"),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	//Attach Lambda Access Policy
+	_, err = iam.NewRolePolicyAttachment(ctx, component.childName("rolePolicyAttachment-LambdaExecutionPolicy"), &iam.RolePolicyAttachmentArgs{
+		Role:      role.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Creating the IAM Policy for SNS Publish
+	snsPublishPolicy, err := iam.NewPolicy(ctx, component.childName("snsPublishPolicy"), &iam.PolicyArgs{
+		Policy: pulumi.String(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Action": "sns:Publish",
+				"Resource": "*"
+			}]
+		}`),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = iam.NewRolePolicyAttachment(ctx, component.childName("rolePolicyAttachment-snspublish"), &iam.RolePolicyAttachmentArgs{
+		Role:      role.Name,
+		PolicyArn: snsPublishPolicy.Arn,
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Allow reading the database password secret, and nothing else
+	_, err = iam.NewRolePolicy(ctx, component.childName("dbSecretReadPolicy"), &iam.RolePolicyArgs{
+		Role: role.Name,
+		Policy: pulumi.Sprintf(`{
+			"Version": "2012-10-17",
+			"Statement": [{
+				"Effect": "Allow",
+				"Action": "secretsmanager:GetSecretValue",
+				"Resource": "%s"
+			}]
+		}`, args.DbSecretArn),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create IAM Instance Profile and connect role
+	instanceProfile, err := iam.NewInstanceProfile(ctx, component.childName("instanceProfile"), &iam.InstanceProfileArgs{
+		Role: role.Name,
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := args.Colors
+	if len(groups) == 0 {
+		groups = []ColorGroup{{
+			AmiId:                     args.AmiId,
+			TargetGroupArn:            args.TargetGroupArn,
+			RequestCountResourceLabel: args.RequestCountResourceLabel,
+			Active:                    true,
+		}}
+	} else if len(args.ServiceTargetGroupArns) > 0 {
+		return nil, fmt.Errorf("app tier %s cannot route services to blue/green colors", name)
+	}
+	component.AutoScalingGroupNames = map[string]pulumi.StringOutput{}
+	for _, group := range groups {
+		asg, err := component.createGroup(ctx, args, group, instanceProfile, userData)
+		if err != nil {
+			return nil, err
+		}
+		if group.Color != "" {
+			component.AutoScalingGroupNames[group.Color] = asg.Name
+		}
+		if group.Active {
+			component.AutoScalingGroupName = asg.Name
+		}
+	}
+
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"autoScalingGroupName": component.AutoScalingGroupName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
+
+// createGroup creates group's launch template and autoscaling group with
+// its scaling policies and target group registrations. A color's group is
+// named after its AMI, so a new AMI replaces the group: the new one waits
+// for healthy instances in the color's target group before the old one is
+// deleted.
+func (component *AppTier) createGroup(ctx *pulumi.Context, args *AppTierArgs, group ColorGroup,
+	instanceProfile *iam.InstanceProfile, userData pulumi.StringOutput) (*autoscaling.Group, error) {
+	suffix := group.suffix()
+
+	// Create Launch Template
+	launchTemplate, err := ec2.NewLaunchTemplate(ctx, component.childName("launchTemplate"+suffix), &ec2.LaunchTemplateArgs{
+		ImageId:      group.AmiId,
+		UserData:     userData,
+		InstanceType: pulumi.String(args.InstanceType),
+		NetworkInterfaces: ec2.LaunchTemplateNetworkInterfaceArray{
+			ec2.LaunchTemplateNetworkInterfaceArgs{
+				AssociatePublicIpAddress: pulumi.String(strconv.FormatBool(!args.PrivateInstances)),
+				SecurityGroups: pulumi.StringArray{
+					args.SecurityGroupId,
+				},
+			},
+		},
+		KeyName: pulumi.String(args.SSHKeyName),
+		IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileArgs{
+			Name: instanceProfile.Name,
+		},
+		Name: pulumi.String("launchTemplate" + suffix),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pin the group to the newest template version so changes roll out
+	launchTemplateVersion := launchTemplate.LatestVersion.ApplyT(func(version int) string {
+		return strconv.Itoa(version)
+	}).(pulumi.StringOutput)
+
+	// Create AutoScaling Group
+	groupArgs := &autoscaling.GroupArgs{
+		MinSize:                pulumi.Int(args.MinSize),
+		MaxSize:                pulumi.Int(args.MaxSize),
+		DesiredCapacity:        pulumi.Int(args.DesiredCapacity),
+		DefaultCooldown:        pulumi.Int(60),
+		VpcZoneIdentifiers:     StringIDs(args.SubnetIDs),
+		HealthCheckGracePeriod: pulumi.Int(400),
+		Tags: autoscaling.GroupTagArray{
+			&autoscaling.GroupTagArgs{
+				Key:               pulumi.String("AutoScaleTag"),
+				Value:             pulumi.String("AutoScaleGpTag"),
+				PropagateAtLaunch: pulumi.Bool(true),
+			},
+		},
+		Name:            pulumi.String("asg"),
+		InstanceRefresh: args.InstanceRefresh.args(),
+	}
+	if group.Color != "" {
+		groupArgs.Name = pulumi.Sprintf("asg%s-%s", suffix, group.AmiId)
+		groupArgs.TargetGroupArns = pulumi.StringArray{group.TargetGroupArn}
+		groupArgs.MinElbCapacity = pulumi.Int(args.MinSize)
+	}
+	if args.MixedInstances != nil {
+		groupArgs.MixedInstancesPolicy = args.MixedInstances.policy(launchTemplate, launchTemplateVersion)
+	} else {
+		groupArgs.LaunchTemplate = &autoscaling.GroupLaunchTemplateArgs{
+			Id:      launchTemplate.ID(),
+			Version: launchTemplateVersion,
+		}
+	}
+	// Scaling policies move the desired capacity and schedules the bounds as
+	// well; an update must not put them back to the configured sizes
+	ignored := []string{"desiredCapacity"}
+	if len(args.Scaling.Schedules) > 0 {
+		ignored = append(ignored, "minSize", "maxSize")
+	}
+	asg, err := autoscaling.NewGroup(ctx, component.childName("asg"+suffix), groupArgs,
+		childOpts(component, pulumi.IgnoreChanges(ignored))...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = component.createScaling(ctx, args, group, asg)
+	if err != nil {
+		return nil, err
+	}
+	if group.Color != "" {
+		return asg, nil
+	}
+
+	_, err = autoscaling.NewAttachment(ctx, component.childName("targpattachment"), &autoscaling.AttachmentArgs{
+		AutoscalingGroupName: asg.Name,
+		LbTargetGroupArn:     group.TargetGroupArn,
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var serviceNames []string
+	for service := range args.ServiceTargetGroupArns {
+		serviceNames = append(serviceNames, service)
+	}
+	sort.Strings(serviceNames)
+	for _, service := range serviceNames {
+		_, err = autoscaling.NewAttachment(ctx, component.childName(service+"TargetGroupAttachment"), &autoscaling.AttachmentArgs{
+			AutoscalingGroupName: asg.Name,
+			LbTargetGroupArn:     args.ServiceTargetGroupArns[service],
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return asg, nil
+}
@@ -0,0 +1,141 @@
+package components
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/lb"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Deployment modes for DeploymentSettings.Mode.
+const (
+	// DeploymentRolling runs one autoscaling group and rolls new AMIs
+	// through it with an instance refresh.
+	DeploymentRolling = "rolling"
+	// DeploymentBlueGreen runs a blue and a green group side by side and
+	// weights the load balancer between them.
+	DeploymentBlueGreen = "blueGreen"
+)
+
+// The colors of a blue/green deployment.
+const (
+	ColorBlue  = "blue"
+	ColorGreen = "green"
+)
+
+// Colors lists the blue/green colors in resource creation order.
+var Colors = []string{ColorBlue, ColorGreen}
+
+// DeploymentSettings picks how new AMIs reach the app instances.
+type DeploymentSettings struct {
+	// Mode is DeploymentRolling or DeploymentBlueGreen.
+	Mode string `json:"mode"`
+	// ActiveColor receives all load balancer traffic in blue/green mode;
+	// flipping it back rolls a deployment back.
+	ActiveColor string `json:"activeColor"`
+	// AmiIds pins the AMI of each color. A color without one runs the AMI
+	// found by name, so the active color must be pinned before a new AMI
+	// is released.
+	AmiIds map[string]string `json:"amiIds"`
+}
+
+// WithDefaults fills in the settings left unset.
+func (s DeploymentSettings) WithDefaults() DeploymentSettings {
+	if s.Mode == "" {
+		s.Mode = DeploymentRolling
+	}
+	if s.ActiveColor == "" {
+		s.ActiveColor = ColorBlue
+	}
+	return s
+}
+
+// BlueGreen reports whether the settings ask for blue/green deployments.
+func (s DeploymentSettings) BlueGreen() bool {
+	return s.Mode == DeploymentBlueGreen
+}
+
+// AmiId returns the AMI color runs, or latest when it is not pinned.
+func (s DeploymentSettings) AmiId(color, latest string) string {
+	if amiId := s.AmiIds[color]; amiId != "" {
+		return amiId
+	}
+	return latest
+}
+
+// Weights returns the share of traffic, in percent, each color receives.
+func (s DeploymentSettings) Weights() map[string]int {
+	weights := map[string]int{}
+	for _, color := range Colors {
+		weights[color] = 0
+	}
+	weights[s.WithDefaults().ActiveColor] = 100
+	return weights
+}
+
+// ColorGroup is one color's autoscaling group in a blue/green app tier.
+type ColorGroup struct {
+	Color string
+	AmiId pulumi.StringInput
+	// TargetGroupArn is the color's target group; the group waits for its
+	// instances to pass health checks there before it counts as created.
+	TargetGroupArn pulumi.StringOutput
+	// RequestCountResourceLabel identifies the color's target group for
+	// ScalingPolicyTargetRequests.
+	RequestCountResourceLabel pulumi.StringInput
+	// Active marks the color receiving traffic.
+	Active bool
+}
+
+// suffix is appended to the names of the color's resources; empty for the
+// single group of a rolling app tier.
+func (g ColorGroup) suffix() string {
+	if g.Color == "" {
+		return ""
+	}
+	return "-" + g.Color
+}
+
+// createColorTargetGroups creates a target group per color in args.Weights
+// and returns the forward action splitting traffic between them by weight.
+// The most weighted color's group becomes the default target group.
+func (component *LoadBalancer) createColorTargetGroups(ctx *pulumi.Context, args *LoadBalancerArgs) (*lb.ListenerDefaultActionForwardArgs, error) {
+	var colors []string
+	for color := range args.Weights {
+		colors = append(colors, color)
+	}
+	sort.Strings(colors)
+
+	component.ColorTargetGroupArns = map[string]pulumi.StringOutput{}
+	component.ColorTargetGroupArnSuffixes = map[string]pulumi.StringOutput{}
+	var targetGroups lb.ListenerDefaultActionForwardTargetGroupArray
+	activeWeight := -1
+	for _, color := range colors {
+		targetGroup, err := newTargetGroup(ctx, component, color+"TargetGroup", args.TargetPort,
+			args.VpcId, args.TargetGroup, pulumi.StringMap{
+				"Color": pulumi.String(color),
+			})
+		if err != nil {
+			return nil, err
+		}
+		weight := args.Weights[color]
+		if weight < 0 || weight > 999 {
+			return nil, fmt.Errorf("weight %d of color %s must be between 0 and 999", weight, color)
+		}
+		targetGroups = append(targetGroups, &lb.ListenerDefaultActionForwardTargetGroupArgs{
+			Arn:    targetGroup.Arn,
+			Weight: pulumi.Int(weight),
+		})
+		component.ColorTargetGroupArns[color] = targetGroup.Arn
+		component.ColorTargetGroupArnSuffixes[color] = targetGroup.ArnSuffix
+		if weight > activeWeight {
+			activeWeight = weight
+			component.TargetGroupArn = targetGroup.Arn
+			component.TargetGroupArnSuffix = targetGroup.ArnSuffix
+		}
+	}
+	return &lb.ListenerDefaultActionForwardArgs{
+		TargetGroups: targetGroups,
+	}, nil
+}
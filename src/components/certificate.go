@@ -0,0 +1,91 @@
+package components
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/acm"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// CertificateArgs configures a DNS-validated ACM certificate.
+type CertificateArgs struct {
+	// NamePrefix prefixes the child resource names; see naming.
+	NamePrefix string
+
+	DomainName string
+	// ZoneId is the public hosted zone the validation record is written to.
+	ZoneId string
+	// ValidationRecordFqdn, when set, is the validation record another
+	// certificate for DomainName already wrote. ACM validates a domain with
+	// the same record in every region, so only one certificate may own it.
+	ValidationRecordFqdn pulumi.StringInput
+}
+
+// Certificate is an ACM certificate for one domain, validated through a
+// Route 53 record so it renews without manual steps.
+type Certificate struct {
+	pulumi.ResourceState
+	naming
+
+	// Arn resolves only once the certificate has been issued, so listeners
+	// using it wait for validation to finish.
+	Arn pulumi.StringOutput
+	// ValidationRecordFqdn is the validation record the certificate uses.
+	ValidationRecordFqdn pulumi.StringOutput
+}
+
+// NewCertificate requests the certificate, writes its validation record unless
+// args.ValidationRecordFqdn is set, and waits for ACM to issue it.
+func NewCertificate(ctx *pulumi.Context, name string, args *CertificateArgs, opts ...pulumi.ResourceOption) (*Certificate, error) {
+	component := &Certificate{naming: naming{args.NamePrefix}}
+	err := ctx.RegisterComponentResource(typePrefix+"Certificate", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := acm.NewCertificate(ctx, component.childName("certificate"), &acm.CertificateArgs{
+		DomainName:       pulumi.String(args.DomainName),
+		ValidationMethod: pulumi.String("DNS"),
+		Tags: pulumi.StringMap{
+			"Name": pulumi.String(args.DomainName),
+		},
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	validationRecordFqdn := args.ValidationRecordFqdn
+	if validationRecordFqdn == nil {
+		// A single-domain certificate has exactly one validation record
+		validationOption := cert.DomainValidationOptions.Index(pulumi.Int(0))
+		validationRecord, err := route53.NewRecord(ctx, component.childName("certificateValidationRecord"), &route53.RecordArgs{
+			ZoneId:         pulumi.String(args.ZoneId),
+			Name:           validationOption.ResourceRecordName().Elem(),
+			Type:           validationOption.ResourceRecordType().Elem(),
+			Records:        pulumi.StringArray{validationOption.ResourceRecordValue().Elem()},
+			Ttl:            pulumi.Int(60),
+			AllowOverwrite: pulumi.Bool(true),
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+		validationRecordFqdn = validationRecord.Fqdn
+	}
+
+	validation, err := acm.NewCertificateValidation(ctx, component.childName("certificateValidation"), &acm.CertificateValidationArgs{
+		CertificateArn:        cert.Arn,
+		ValidationRecordFqdns: pulumi.StringArray{validationRecordFqdn},
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	component.Arn = validation.CertificateArn
+	component.ValidationRecordFqdn = validationRecordFqdn.ToStringOutput()
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"arn": validation.CertificateArn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
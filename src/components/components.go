@@ -0,0 +1,41 @@
+// Package components groups the stack's resources into one Pulumi component
+// per tier so the resource tree mirrors the architecture and the pieces can be
+// reused by other programs.
+package components
+
+import "github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+// typePrefix namespaces the component type tokens registered by this package.
+const typePrefix = "csye6225:components:"
+
+// childOpts parents a resource under its component. The NoParent alias keeps
+// resources created before the split into components from being replaced.
+func childOpts(parent pulumi.Resource, opts ...pulumi.ResourceOption) []pulumi.ResourceOption {
+	return append([]pulumi.ResourceOption{
+		pulumi.Parent(parent),
+		pulumi.Aliases([]pulumi.Alias{{NoParent: pulumi.Bool(true)}}),
+	}, opts...)
+}
+
+// naming prefixes the names of a component's child resources. Resource
+// names must be unique within a stack, so components created once per region
+// take a NamePrefix arg, set on every copy but the stack's own region's,
+// which keeps its original names.
+type naming struct {
+	prefix string
+}
+
+// childName returns the name of the child resource called name.
+func (n naming) childName(name string) string {
+	return n.prefix + name
+}
+
+// StringIDs converts resource IDs into the string array most AWS args and
+// stack outputs take.
+func StringIDs(ids []pulumi.IDOutput) pulumi.StringArray {
+	var out pulumi.StringArray
+	for _, id := range ids {
+		out = append(out, id.ToStringOutput())
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package components
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/rds"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/secretsmanager"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// DatabaseArgs configures the MySQL RDS instance.
+type DatabaseArgs struct {
+	// NamePrefix prefixes the child resource names; see naming.
+	NamePrefix string
+
+	// SubnetIDs are the private subnets the instance may be placed in.
+	SubnetIDs       []pulumi.IDOutput
+	SecurityGroupId pulumi.IDOutput
+
+	Identifier string
+	DbName     string
+	Username   string
+	// Password is the master password. When nil, one is generated on the
+	// first deployment and kept from then on.
+	Password pulumi.StringInput
+}
+
+// Database is a single-AZ MySQL instance reachable only from inside the VPC.
+type Database struct {
+	pulumi.ResourceState
+	naming
+
+	// Endpoint is the instance's host:port.
+	Endpoint pulumi.StringOutput
+	Address  pulumi.StringOutput
+	Port     pulumi.IntOutput
+	DbName   pulumi.StringOutput
+	Username pulumi.StringOutput
+	// PasswordSecretArn is the Secrets Manager secret holding the master
+	// credentials as JSON with "username" and "password" keys. It is never
+	// rotated, as the app instances read it only at boot.
+	PasswordSecretArn pulumi.StringOutput
+}
+
+// NewDatabase creates the RDS instance with its parameter and subnet groups.
+func NewDatabase(ctx *pulumi.Context, name string, args *DatabaseArgs, opts ...pulumi.ResourceOption) (*Database, error) {
+	component := &Database{naming: naming{args.NamePrefix}}
+	err := ctx.RegisterComponentResource(typePrefix+"Database", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create Parameter group for RDS
+	dbParamGp, err := rds.NewParameterGroup(ctx, component.childName("rdsparamgroup"), &rds.ParameterGroupArgs{
+		Family: pulumi.String("mysql8.0"),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	dbPvtSubnetGroup, err := rds.NewSubnetGroup(ctx, component.childName("dbsubnetgroup"), &rds.SubnetGroupArgs{
+		SubnetIds: StringIDs(args.SubnetIDs), // Use the private subnets
+		Tags: pulumi.StringMap{
+			"Name": pulumi.String("MyDBSubnetGroup"),
+		},
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The instance takes its password from the secret, so the two always
+	// agree, even when the password was generated on an earlier run
+	var password pulumi.StringOutput
+	component.PasswordSecretArn, password, err = newPasswordSecret(ctx, component, args)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceArgs := &rds.InstanceArgs{
+		AllocatedStorage:   pulumi.Int(10),
+		DbName:             pulumi.String(args.DbName),
+		Engine:             pulumi.String("mysql"),
+		EngineVersion:      pulumi.String("8.0"),
+		InstanceClass:      pulumi.String("db.t3.micro"), //check cheapest
+		ParameterGroupName: dbParamGp.Name,
+		Password:           password,
+		SkipFinalSnapshot:  pulumi.Bool(true),
+		Username:           pulumi.String(args.Username),
+		MultiAz:            pulumi.Bool(false),
+		Identifier:         pulumi.String(args.Identifier),
+		DbSubnetGroupName:  dbPvtSubnetGroup.Name,
+		PubliclyAccessible: pulumi.Bool(false),
+		VpcSecurityGroupIds: pulumi.StringArray{
+			args.SecurityGroupId,
+		},
+	}
+	myRdsInstance, err := rds.NewInstance(ctx, component.childName("rdsinstance"), instanceArgs, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	component.Endpoint = myRdsInstance.Endpoint
+	component.Address = myRdsInstance.Address
+	component.Port = myRdsInstance.Port
+	component.DbName = myRdsInstance.DbName
+	component.Username = myRdsInstance.Username
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"endpoint":          myRdsInstance.Endpoint,
+		"passwordSecretArn": component.PasswordSecretArn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
+
+// newPasswordSecret stores the master credentials in Secrets Manager in the
+// same format RDS uses for the passwords it manages, and returns the secret's
+// ARN and the password it holds. A generated password is only written when
+// the secret version is created; later runs keep the stored one.
+func newPasswordSecret(ctx *pulumi.Context, component *Database, args *DatabaseArgs) (pulumi.StringOutput, pulumi.StringOutput, error) {
+	secret, err := secretsmanager.NewSecret(ctx, component.childName("dbPasswordSecret"), &secretsmanager.SecretArgs{
+		Description: pulumi.String("Master credentials of RDS instance " + args.Identifier),
+	}, childOpts(component)...)
+	if err != nil {
+		return pulumi.StringOutput{}, pulumi.StringOutput{}, err
+	}
+
+	password := args.Password
+	versionOpts := childOpts(component)
+	if password == nil {
+		generated, err := generatePassword()
+		if err != nil {
+			return pulumi.StringOutput{}, pulumi.StringOutput{}, err
+		}
+		password = pulumi.String(generated)
+		versionOpts = append(versionOpts, pulumi.IgnoreChanges([]string{"secretString"}))
+	}
+	credentials := password.ToStringOutput().ApplyT(func(password string) (string, error) {
+		b, err := json.Marshal(map[string]string{
+			"username": args.Username,
+			"password": password,
+		})
+		return string(b), err
+	}).(pulumi.StringOutput)
+	version, err := secretsmanager.NewSecretVersion(ctx, component.childName("dbPasswordSecretVersion"), &secretsmanager.SecretVersionArgs{
+		SecretId:     secret.ID(),
+		SecretString: pulumi.ToSecret(credentials).(pulumi.StringOutput),
+	}, versionOpts...)
+	if err != nil {
+		return pulumi.StringOutput{}, pulumi.StringOutput{}, err
+	}
+
+	stored := version.SecretString.Elem().ApplyT(func(credentials string) (string, error) {
+		var c struct {
+			Password string `json:"password"`
+		}
+		err := json.Unmarshal([]byte(credentials), &c)
+		return c.Password, err
+	}).(pulumi.StringOutput)
+	return secret.Arn, pulumi.ToSecret(stored).(pulumi.StringOutput), nil
+}
+
+// passwordChars are the characters of generated passwords; RDS rejects
+// some punctuation in MySQL master passwords, so none is used.
+const passwordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// generatePassword returns a random 32 character password.
+func generatePassword() (string, error) {
+	b := make([]byte, 32)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordChars))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordChars[n.Int64()]
+	}
+	return string(b), nil
+}
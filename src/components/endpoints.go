@@ -0,0 +1,115 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// GatewayEndpointServices are the services reachable through gateway
+// endpoints, which are free and attach to route tables.
+var GatewayEndpointServices = []string{"dynamodb", "s3"}
+
+// VpcEndpointsArgs configures private access to AWS APIs from the VPC.
+type VpcEndpointsArgs struct {
+	// NamePrefix prefixes the child resource names; see naming.
+	NamePrefix string
+
+	VpcId        pulumi.IDOutput
+	VpcCidrBlock pulumi.StringOutput
+
+	// GatewayServices, e.g. "dynamodb", are routed through RouteTableIDs.
+	GatewayServices []string
+	RouteTableIDs   []pulumi.IDOutput
+
+	// InterfaceServices, e.g. "sns" or "logs", get an endpoint network
+	// interface in each of SubnetIDs, one subnet per zone.
+	InterfaceServices []string
+	SubnetIDs         []pulumi.IDOutput
+}
+
+// VpcEndpoints lets instances without internet egress reach AWS services.
+type VpcEndpoints struct {
+	pulumi.ResourceState
+	naming
+
+	// SecurityGroupId guards the interface endpoints; empty when there are none.
+	SecurityGroupId pulumi.IDOutput
+}
+
+// NewVpcEndpoints creates the gateway and interface endpoints in args.
+func NewVpcEndpoints(ctx *pulumi.Context, name string, args *VpcEndpointsArgs, opts ...pulumi.ResourceOption) (*VpcEndpoints, error) {
+	component := &VpcEndpoints{naming: naming{args.NamePrefix}}
+	err := ctx.RegisterComponentResource(typePrefix+"VpcEndpoints", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	region, err := aws.GetRegion(ctx, nil, pulumi.Parent(component))
+	if err != nil {
+		return nil, err
+	}
+	serviceName := func(service string) pulumi.String {
+		return pulumi.String(fmt.Sprintf("com.amazonaws.%s.%s", region.Name, service))
+	}
+
+	for _, service := range args.GatewayServices {
+		_, err := ec2.NewVpcEndpoint(ctx, component.childName("vpcEndpoint-"+service), &ec2.VpcEndpointArgs{
+			VpcId:           args.VpcId,
+			ServiceName:     serviceName(service),
+			VpcEndpointType: pulumi.String("Gateway"),
+			RouteTableIds:   StringIDs(args.RouteTableIDs),
+			Tags: pulumi.StringMap{
+				"Name": pulumi.String(service + " endpoint"),
+			},
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(args.InterfaceServices) > 0 {
+		// Interface endpoints accept HTTPS from anywhere in the VPC
+		endpointSecurityGroup, err := ec2.NewSecurityGroup(ctx, component.childName("vpcEndpointSecurityGroup"), &ec2.SecurityGroupArgs{
+			Description: pulumi.String("VPC interface endpoints"),
+			VpcId:       args.VpcId,
+			Ingress: ec2.SecurityGroupIngressArray{
+				ec2.SecurityGroupIngressArgs{
+					FromPort:   pulumi.Int(443),
+					ToPort:     pulumi.Int(443),
+					Protocol:   pulumi.String("tcp"),
+					CidrBlocks: pulumi.StringArray{args.VpcCidrBlock},
+				},
+			},
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+		component.SecurityGroupId = endpointSecurityGroup.ID()
+
+		for _, service := range args.InterfaceServices {
+			_, err := ec2.NewVpcEndpoint(ctx, component.childName("vpcEndpoint-"+service), &ec2.VpcEndpointArgs{
+				VpcId:             args.VpcId,
+				ServiceName:       serviceName(service),
+				VpcEndpointType:   pulumi.String("Interface"),
+				SubnetIds:         StringIDs(args.SubnetIDs),
+				SecurityGroupIds:  pulumi.StringArray{endpointSecurityGroup.ID()},
+				PrivateDnsEnabled: pulumi.Bool(true),
+				Tags: pulumi.StringMap{
+					"Name": pulumi.String(service + " endpoint"),
+				},
+			}, childOpts(component)...)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
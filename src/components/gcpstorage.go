@@ -0,0 +1,70 @@
+package components
+
+import (
+	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/serviceaccount"
+	"github.com/pulumi/pulumi-gcp/sdk/v7/go/gcp/storage"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// GcpStorageAccessArgs configures the service account granted bucket access.
+type GcpStorageAccessArgs struct {
+	BucketName  string
+	AccountId   string
+	DisplayName string
+}
+
+// GcpStorageAccess is a GCP service account, with a key, that may manage the
+// objects of one existing storage bucket.
+type GcpStorageAccess struct {
+	pulumi.ResourceState
+
+	Email pulumi.StringOutput
+	// PrivateKey is the base64-encoded JSON credentials file of the key,
+	// marked secret.
+	PrivateKey pulumi.StringOutput
+}
+
+// NewGcpStorageAccess creates the service account, its key and the bucket IAM binding.
+func NewGcpStorageAccess(ctx *pulumi.Context, name string, args *GcpStorageAccessArgs, opts ...pulumi.ResourceOption) (*GcpStorageAccess, error) {
+	component := &GcpStorageAccess{}
+	err := ctx.RegisterComponentResource(typePrefix+"GcpStorageAccess", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	sa, err := serviceaccount.NewAccount(ctx, "serviceAccount", &serviceaccount.AccountArgs{
+		AccountId:   pulumi.String(args.AccountId),
+		DisplayName: pulumi.String(args.DisplayName),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+	// GCP SA KEY
+	key, err := serviceaccount.NewKey(ctx, "mykey", &serviceaccount.KeyArgs{
+		ServiceAccountId: sa.Name,
+		PublicKeyType:    pulumi.String("TYPE_X509_PEM_FILE"),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// GCP BUCKET IAM
+	_, err = storage.NewBucketIAMMember(ctx, "member", &storage.BucketIAMMemberArgs{
+		Bucket: pulumi.String(args.BucketName),
+		Role:   pulumi.String("roles/storage.objectAdmin"),
+		Member: sa.Member,
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	component.Email = sa.Email
+	component.PrivateKey = pulumi.ToSecret(key.PrivateKey).(pulumi.StringOutput)
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"email": sa.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
@@ -0,0 +1,270 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/lb"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// DefaultSslPolicy allows only TLS 1.2 and 1.3 on the HTTPS listener.
+const DefaultSslPolicy = "ELBSecurityPolicy-TLS13-1-2-2021-06"
+
+// MinLoadBalancerZones is the number of availability zones an application
+// load balancer must have subnets in.
+const MinLoadBalancerZones = 2
+
+// LoadBalancerArgs configures the public application load balancer.
+type LoadBalancerArgs struct {
+	// NamePrefix prefixes the child resource names; see naming.
+	NamePrefix string
+	// ElbNamePrefix, when set, has AWS name the load balancer and target
+	// groups with this prefix, at most 6 characters, and a unique suffix.
+	// Otherwise their names derive from the resource names, and must stay
+	// within ELB's 32 characters.
+	ElbNamePrefix string
+
+	VpcId pulumi.IDOutput
+	// SubnetIDs are public subnets, one per zone, at least MinLoadBalancerZones.
+	SubnetIDs       []pulumi.IDOutput
+	SecurityGroupId pulumi.IDOutput
+
+	// TargetPort is the port the application listens on, for requests no
+	// entry of Services matches.
+	TargetPort  int
+	TargetGroup TargetGroupSettings
+	// Weights, when set, replace the default target group with one per
+	// blue/green color, sharing requests by weight.
+	Weights map[string]int
+	// Services get their own target group, routed to by host and path.
+	Services []Service
+
+	CertificateArn pulumi.StringInput
+	// AdditionalCertificateArns are served by SNI for further hostnames.
+	AdditionalCertificateArns []string
+	// SslPolicy is the listener's TLS negotiation policy; DefaultSslPolicy
+	// when empty.
+	SslPolicy string
+	// HttpRedirect adds a port 80 listener redirecting clients to HTTPS.
+	HttpRedirect bool
+
+	// DomainName is aliased to the load balancer in the hosted zone ZoneId.
+	DomainName string
+	ZoneId     string
+	// RecordRouting, when set, makes the alias record one of a set sharing
+	// DomainName across the load balancers of several regions.
+	RecordRouting *RecordRouting
+}
+
+// DNS routing policies for RecordRouting.Policy.
+const (
+	// RoutingLatency answers with the region closest to the client.
+	RoutingLatency = "latency"
+	// RoutingFailover answers with the primary region while its load
+	// balancer is healthy, and the secondary one otherwise.
+	RoutingFailover = "failover"
+)
+
+// RecordRouting places a load balancer's alias record in a routed record set.
+type RecordRouting struct {
+	// Policy is RoutingLatency or RoutingFailover.
+	Policy string
+	// Region is the load balancer's region, which also identifies the
+	// record within the set.
+	Region string
+	// Primary marks the record answered first under RoutingFailover.
+	Primary bool
+}
+
+// LoadBalancer is an internet-facing ALB terminating HTTPS in front of a
+// default target group and one per routed service, with a DNS alias record
+// pointing at it.
+type LoadBalancer struct {
+	pulumi.ResourceState
+	naming
+	elbNamePrefix string
+
+	Arn     pulumi.StringOutput
+	DnsName pulumi.StringOutput
+	// DomainName is the DNS alias record's name.
+	DomainName     pulumi.StringOutput
+	ZoneId         pulumi.StringOutput
+	TargetGroupArn pulumi.StringOutput
+	// ArnSuffix and TargetGroupArnSuffix identify the load balancer and
+	// default target group in CloudWatch metrics.
+	ArnSuffix            pulumi.StringOutput
+	TargetGroupArnSuffix pulumi.StringOutput
+	// ServiceTargetGroupArns holds the target group of each service by name.
+	ServiceTargetGroupArns map[string]pulumi.StringOutput
+	// ColorTargetGroupArns and ColorTargetGroupArnSuffixes hold the target
+	// group of each blue/green color; nil unless args.Weights is set.
+	ColorTargetGroupArns        map[string]pulumi.StringOutput
+	ColorTargetGroupArnSuffixes map[string]pulumi.StringOutput
+}
+
+// NewLoadBalancer creates the ALB, its target group, listeners and DNS record.
+func NewLoadBalancer(ctx *pulumi.Context, name string, args *LoadBalancerArgs, opts ...pulumi.ResourceOption) (*LoadBalancer, error) {
+	if len(args.SubnetIDs) < MinLoadBalancerZones {
+		return nil, fmt.Errorf("load balancer %s needs subnets in at least %d availability zones, got %d",
+			name, MinLoadBalancerZones, len(args.SubnetIDs))
+	}
+
+	component := &LoadBalancer{naming: naming{args.NamePrefix}, elbNamePrefix: args.ElbNamePrefix}
+	err := ctx.RegisterComponentResource(typePrefix+"LoadBalancer", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create load balancer
+	loadBalancerArgs := &lb.LoadBalancerArgs{
+		Internal:         pulumi.Bool(false),
+		LoadBalancerType: pulumi.String("application"),
+		SecurityGroups: pulumi.StringArray{
+			args.SecurityGroupId,
+		},
+		Subnets:                  StringIDs(args.SubnetIDs),
+		EnableDeletionProtection: pulumi.Bool(false),
+
+		Tags: pulumi.StringMap{
+			"Environment": pulumi.String("production"),
+		},
+	}
+	if args.ElbNamePrefix != "" {
+		loadBalancerArgs.NamePrefix = pulumi.String(args.ElbNamePrefix)
+	}
+	apl, err := lb.NewLoadBalancer(ctx, component.childName("testloadBalancer"), loadBalancerArgs, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the default target group, or one per color weighted against
+	// each other
+	defaultAction := &lb.ListenerDefaultActionArgs{
+		Type: pulumi.String("forward"),
+	}
+	if len(args.Weights) > 0 {
+		defaultAction.Forward, err = component.createColorTargetGroups(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		targetGroup, err := newTargetGroup(ctx, component, "testTargetgroup", args.TargetPort,
+			args.VpcId, args.TargetGroup, nil)
+		if err != nil {
+			return nil, err
+		}
+		defaultAction.TargetGroupArn = targetGroup.Arn
+		component.TargetGroupArn = targetGroup.Arn
+		component.TargetGroupArnSuffix = targetGroup.ArnSuffix
+	}
+
+	sslPolicy := args.SslPolicy
+	if sslPolicy == "" {
+		sslPolicy = DefaultSslPolicy
+	}
+	httpsListener, err := lb.NewListener(ctx, component.childName("myListenerALB"), &lb.ListenerArgs{
+		DefaultActions:  lb.ListenerDefaultActionArray{defaultAction},
+		LoadBalancerArn: apl.Arn,
+		Port:            pulumi.Int(443),
+		SslPolicy:       pulumi.String(sslPolicy),
+		CertificateArn:  args.CertificateArn,
+		Protocol:        pulumi.String("HTTPS"),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = component.createServiceRoutes(ctx, args, httpsListener)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, certificateArn := range args.AdditionalCertificateArns {
+		_, err = lb.NewListenerCertificate(ctx, component.childName(fmt.Sprintf("listenerCertificate-%d", i+1)), &lb.ListenerCertificateArgs{
+			ListenerArn:    httpsListener.Arn,
+			CertificateArn: pulumi.String(certificateArn),
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if args.HttpRedirect {
+		_, err = lb.NewListener(ctx, component.childName("httpRedirectListener"), &lb.ListenerArgs{
+			DefaultActions: lb.ListenerDefaultActionArray{
+				&lb.ListenerDefaultActionArgs{
+					Type: pulumi.String("redirect"),
+					Redirect: &lb.ListenerDefaultActionRedirectArgs{
+						Port:       pulumi.String("443"),
+						Protocol:   pulumi.String("HTTPS"),
+						StatusCode: pulumi.String("HTTP_301"),
+					},
+				},
+			},
+			LoadBalancerArn: apl.Arn,
+			Port:            pulumi.Int(80),
+			Protocol:        pulumi.String("HTTP"),
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Create an A record aliasing the domain to the load balancer
+	alias := &route53.RecordAliasArgs{
+		Name:                 apl.DnsName,
+		ZoneId:               apl.ZoneId,
+		EvaluateTargetHealth: pulumi.Bool(false),
+	}
+	recordArgs := &route53.RecordArgs{
+		Name:    pulumi.String(args.DomainName),
+		Type:    pulumi.String("A"),
+		Aliases: route53.RecordAliasArray{alias},
+		ZoneId:  pulumi.String(args.ZoneId),
+	}
+	if routing := args.RecordRouting; routing != nil {
+		// Routed records fall through to another region when this load
+		// balancer has no healthy targets
+		alias.EvaluateTargetHealth = pulumi.Bool(true)
+		recordArgs.SetIdentifier = pulumi.String(routing.Region)
+		switch routing.Policy {
+		case RoutingLatency:
+			recordArgs.LatencyRoutingPolicies = route53.RecordLatencyRoutingPolicyArray{
+				&route53.RecordLatencyRoutingPolicyArgs{Region: pulumi.String(routing.Region)},
+			}
+		case RoutingFailover:
+			failover := "SECONDARY"
+			if routing.Primary {
+				failover = "PRIMARY"
+			}
+			recordArgs.FailoverRoutingPolicies = route53.RecordFailoverRoutingPolicyArray{
+				&route53.RecordFailoverRoutingPolicyArgs{Type: pulumi.String(failover)},
+			}
+		default:
+			return nil, fmt.Errorf("unknown DNS routing policy %q", routing.Policy)
+		}
+	}
+	// A simple and a routed record cannot share a name, so switching
+	// between them has to delete the old record first
+	record, err := route53.NewRecord(ctx, component.childName("record"), recordArgs,
+		childOpts(component, pulumi.DeleteBeforeReplace(true))...)
+	if err != nil {
+		return nil, err
+	}
+
+	component.Arn = apl.Arn
+	component.DnsName = apl.DnsName
+	component.DomainName = record.Name
+	component.ZoneId = apl.ZoneId
+	component.ArnSuffix = apl.ArnSuffix
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"arn":            apl.Arn,
+		"dnsName":        apl.DnsName,
+		"targetGroupArn": component.TargetGroupArn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
@@ -0,0 +1,61 @@
+package components
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/autoscaling"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// DefaultSpotAllocationStrategy favours spot pools that are both cheap and
+// unlikely to be interrupted.
+const DefaultSpotAllocationStrategy = "price-capacity-optimized"
+
+// SpotAllocationStrategies are the strategies EC2 Auto Scaling accepts for
+// spot capacity.
+var SpotAllocationStrategies = []string{
+	"price-capacity-optimized", "capacity-optimized", "capacity-optimized-prioritized", "lowest-price",
+}
+
+// MixedInstancesSettings runs an autoscaling group on several instance types
+// with a mix of on-demand and spot capacity.
+type MixedInstancesSettings struct {
+	// InstanceTypes replace the launch template's type, in priority order.
+	InstanceTypes []string `json:"instanceTypes"`
+	// OnDemandBaseCapacity instances are always on-demand; of the capacity
+	// above it, OnDemandPercentageAboveBase percent is on-demand and the
+	// rest spot.
+	OnDemandBaseCapacity        int `json:"onDemandBaseCapacity"`
+	OnDemandPercentageAboveBase int `json:"onDemandPercentageAboveBase"`
+	// SpotAllocationStrategy is one of SpotAllocationStrategies;
+	// DefaultSpotAllocationStrategy when empty.
+	SpotAllocationStrategy string `json:"spotAllocationStrategy"`
+}
+
+// policy builds the group's mixed instances policy around the given version
+// of launchTemplate.
+func (s *MixedInstancesSettings) policy(launchTemplate *ec2.LaunchTemplate, version pulumi.StringInput) *autoscaling.GroupMixedInstancesPolicyArgs {
+	strategy := s.SpotAllocationStrategy
+	if strategy == "" {
+		strategy = DefaultSpotAllocationStrategy
+	}
+	var overrides autoscaling.GroupMixedInstancesPolicyLaunchTemplateOverrideArray
+	for _, instanceType := range s.InstanceTypes {
+		overrides = append(overrides, &autoscaling.GroupMixedInstancesPolicyLaunchTemplateOverrideArgs{
+			InstanceType: pulumi.String(instanceType),
+		})
+	}
+	return &autoscaling.GroupMixedInstancesPolicyArgs{
+		InstancesDistribution: &autoscaling.GroupMixedInstancesPolicyInstancesDistributionArgs{
+			OnDemandBaseCapacity:                pulumi.Int(s.OnDemandBaseCapacity),
+			OnDemandPercentageAboveBaseCapacity: pulumi.Int(s.OnDemandPercentageAboveBase),
+			SpotAllocationStrategy:              pulumi.String(strategy),
+		},
+		LaunchTemplate: &autoscaling.GroupMixedInstancesPolicyLaunchTemplateArgs{
+			LaunchTemplateSpecification: &autoscaling.GroupMixedInstancesPolicyLaunchTemplateLaunchTemplateSpecificationArgs{
+				LaunchTemplateId: launchTemplate.ID(),
+				Version:          version,
+			},
+			Overrides: overrides,
+		},
+	}
+}
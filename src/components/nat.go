@@ -0,0 +1,151 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ssm"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// NAT modes for NetworkArgs.NatMode.
+const (
+	// NatNone leaves private subnets without a route out of the VPC.
+	NatNone = "none"
+	// NatSingle shares one NAT gateway, in the first zone, across all zones.
+	NatSingle = "single"
+	// NatPerAz gives every zone its own NAT gateway and private route table,
+	// so losing a zone does not cut the others off.
+	NatPerAz = "perAz"
+	// NatInstance routes through a small EC2 instance instead of a managed
+	// NAT gateway, which is much cheaper for dev stacks.
+	NatInstance = "instance"
+)
+
+// natInstanceAmiParameter is the SSM parameter holding the latest Amazon
+// Linux 2023 AMI, used for NAT instances.
+const natInstanceAmiParameter = "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64"
+
+// natInstanceUserData turns an Amazon Linux 2023 host into a NAT router.
+const natInstanceUserData = `#!/bin/bash
+dnf install -y iptables-services
+sysctl -w net.ipv4.ip_forward=1
+echo "net.ipv4.ip_forward = 1" > /etc/sysctl.d/90-nat.conf
+IFACE=$(ip route show default | awk '{print $5}')
+iptables -t nat -A POSTROUTING -o "$IFACE" -j MASQUERADE
+iptables -F FORWARD
+service iptables save
+systemctl enable --now iptables
+`
+
+// createNat adds the default route of every private route table according to
+// args.NatMode. subnetIDs are public subnets, one per zone, for the NAT to
+// live in.
+func (component *Network) createNat(ctx *pulumi.Context, args *NetworkArgs, vpc *ec2.Vpc,
+	subnetIDs []pulumi.IDOutput, routeTables []*ec2.RouteTable, igwAttachment pulumi.Resource) error {
+	switch args.NatMode {
+	case "", NatNone:
+		return nil
+	case NatSingle, NatPerAz:
+		for i, routeTable := range routeTables {
+			natGateway, err := newNatGateway(ctx, component, i, subnetIDs[i], igwAttachment)
+			if err != nil {
+				return err
+			}
+			_, err = ec2.NewRoute(ctx, component.childName(fmt.Sprintf("privateNatRoute-%d", i+1)), &ec2.RouteArgs{
+				RouteTableId:         routeTable.ID(),
+				DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
+				NatGatewayId:         natGateway.ID(),
+			}, childOpts(component)...)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case NatInstance:
+		natInstance, err := newNatInstance(ctx, component, args, vpc, subnetIDs[0])
+		if err != nil {
+			return err
+		}
+		for i, routeTable := range routeTables {
+			_, err = ec2.NewRoute(ctx, component.childName(fmt.Sprintf("privateNatRoute-%d", i+1)), &ec2.RouteArgs{
+				RouteTableId:         routeTable.ID(),
+				DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
+				NetworkInterfaceId:   natInstance.PrimaryNetworkInterfaceId,
+			}, childOpts(component)...)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown NAT mode %q", args.NatMode)
+	}
+}
+
+// newNatGateway creates the i'th NAT gateway and its elastic IP.
+func newNatGateway(ctx *pulumi.Context, component *Network, i int, subnetID pulumi.IDOutput,
+	igwAttachment pulumi.Resource) (*ec2.NatGateway, error) {
+	eip, err := ec2.NewEip(ctx, component.childName(fmt.Sprintf("natEip-%d", i+1)), &ec2.EipArgs{
+		Domain: pulumi.String("vpc"),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+	return ec2.NewNatGateway(ctx, component.childName(fmt.Sprintf("natGateway-%d", i+1)), &ec2.NatGatewayArgs{
+		AllocationId: eip.ID(),
+		SubnetId:     subnetID,
+		Tags: pulumi.StringMap{
+			"Name": pulumi.String(fmt.Sprintf("NAT Gateway %d", i+1)),
+		},
+	}, childOpts(component, pulumi.DependsOn([]pulumi.Resource{igwAttachment}))...)
+}
+
+// newNatInstance creates a NAT instance reachable from anywhere in the VPC.
+// Newer AMIs are ignored once it exists so an AMI release does not cut egress.
+func newNatInstance(ctx *pulumi.Context, component *Network, args *NetworkArgs, vpc *ec2.Vpc,
+	subnetID pulumi.IDOutput) (*ec2.Instance, error) {
+	ami, err := ssm.LookupParameter(ctx, &ssm.LookupParameterArgs{
+		Name: natInstanceAmiParameter,
+	}, pulumi.Parent(component))
+	if err != nil {
+		return nil, err
+	}
+
+	natSecurityGroup, err := ec2.NewSecurityGroup(ctx, component.childName("natInstanceSecurityGroup"), &ec2.SecurityGroupArgs{
+		Description: pulumi.String("NAT instance"),
+		VpcId:       vpc.ID(),
+		Ingress: ec2.SecurityGroupIngressArray{
+			ec2.SecurityGroupIngressArgs{
+				FromPort:   pulumi.Int(0),
+				ToPort:     pulumi.Int(0),
+				Protocol:   pulumi.String("-1"),
+				CidrBlocks: pulumi.StringArray{vpc.CidrBlock},
+			},
+		},
+		Egress: ec2.SecurityGroupEgressArray{
+			ec2.SecurityGroupEgressArgs{
+				FromPort:   pulumi.Int(0),
+				ToPort:     pulumi.Int(0),
+				Protocol:   pulumi.String("-1"),
+				CidrBlocks: pulumi.StringArray{pulumi.String("0.0.0.0/0")},
+			},
+		},
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return ec2.NewInstance(ctx, component.childName("natInstance"), &ec2.InstanceArgs{
+		Ami:                      pulumi.String(ami.Value),
+		InstanceType:             pulumi.String(args.NatInstanceType),
+		SubnetId:                 subnetID,
+		VpcSecurityGroupIds:      pulumi.StringArray{natSecurityGroup.ID()},
+		AssociatePublicIpAddress: pulumi.Bool(true),
+		SourceDestCheck:          pulumi.Bool(false),
+		UserData:                 pulumi.String(natInstanceUserData),
+		Tags: pulumi.StringMap{
+			"Name": pulumi.String("NAT Instance"),
+		},
+	}, childOpts(component, pulumi.IgnoreChanges([]string{"ami"}))...)
+}
@@ -0,0 +1,243 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// NetworkArgs configures the VPC, its gateway, route tables and subnets.
+type NetworkArgs struct {
+	// NamePrefix prefixes the child resource names; see naming.
+	NamePrefix string
+
+	// CidrBlock is the VPC CIDR, e.g. 10.2.0.0/16.
+	CidrBlock string
+	// AvailabilityZones receive one subnet of every tier each.
+	AvailabilityZones []string
+	// Tiers are the rows of subnets created in every zone, in CIDR order.
+	Tiers []SubnetTier
+
+	VpcName                       string
+	InternetGatewayName           string
+	InternetGatewayAttachmentName string
+	PublicRouteTableName          string
+	PrivateRouteTableName         string
+	PublicRouteName               string
+
+	// NatMode gives private subnets a route to the internet; see NatNone.
+	NatMode string
+	// NatInstanceType is the EC2 instance type used when NatMode is NatInstance.
+	NatInstanceType string
+}
+
+// Network is a VPC with a subnet of every tier in each availability zone.
+type Network struct {
+	pulumi.ResourceState
+	naming
+
+	VpcId              pulumi.IDOutput
+	VpcCidrBlock       pulumi.StringOutput
+	PublicRouteTableId pulumi.IDOutput
+	// PrivateRouteTableIDs has one table per zone with NatPerAz, else one.
+	PrivateRouteTableIDs []pulumi.IDOutput
+	// Subnet IDs of every tier of each kind, zone by zone.
+	PublicSubnetIDs   []pulumi.IDOutput
+	PrivateSubnetIDs  []pulumi.IDOutput
+	IsolatedSubnetIDs []pulumi.IDOutput
+	// TierSubnetIDs holds the subnet IDs of each tier by tier name.
+	TierSubnetIDs map[string][]pulumi.IDOutput
+	// RouteTableIDs lists every route table in the VPC.
+	RouteTableIDs []pulumi.IDOutput
+}
+
+// NewNetwork creates the VPC and subnets described by args.
+func NewNetwork(ctx *pulumi.Context, name string, args *NetworkArgs, opts ...pulumi.ResourceOption) (*Network, error) {
+	plans, err := PlanSubnets(args.CidrBlock, args.AvailabilityZones, args.Tiers)
+	if err != nil {
+		return nil, err
+	}
+
+	component := &Network{naming: naming{args.NamePrefix}, TierSubnetIDs: map[string][]pulumi.IDOutput{}}
+	err = ctx.RegisterComponentResource(typePrefix+"Network", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create VPC. Interface endpoints with private DNS need both DNS
+	// attributes enabled.
+	myVpc, err := ec2.NewVpc(ctx, component.childName(args.VpcName), &ec2.VpcArgs{
+		CidrBlock:          pulumi.String(args.CidrBlock),
+		EnableDnsSupport:   pulumi.Bool(true),
+		EnableDnsHostnames: pulumi.Bool(true),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create Internet Gateway
+	internetGateway, err := ec2.NewInternetGateway(ctx, component.childName(args.InternetGatewayName), nil, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create Internet Gateway Attachment
+	igwAttachment, err := ec2.NewInternetGatewayAttachment(ctx, component.childName(args.InternetGatewayAttachmentName), &ec2.InternetGatewayAttachmentArgs{
+		InternetGatewayId: internetGateway.ID(),
+		VpcId:             myVpc.ID(),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create Public Route Table
+	publicRouteTable, err := ec2.NewRouteTable(ctx, component.childName(args.PublicRouteTableName), &ec2.RouteTableArgs{
+		VpcId: myVpc.ID(),
+		Tags: pulumi.StringMap{
+			"Name": pulumi.String("Public Route Table"),
+		},
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create Private Route Table, one per zone when each zone has its own NAT
+	var privateRouteTables []*ec2.RouteTable
+	if args.NatMode == NatPerAz {
+		for _, az := range args.AvailabilityZones {
+			privateRouteTable, err := ec2.NewRouteTable(ctx, component.childName(args.PrivateRouteTableName+"-"+az), &ec2.RouteTableArgs{
+				VpcId: myVpc.ID(),
+				Tags: pulumi.StringMap{
+					"Name": pulumi.String("Private Route Table " + az),
+				},
+			}, childOpts(component)...)
+			if err != nil {
+				return nil, err
+			}
+			privateRouteTables = append(privateRouteTables, privateRouteTable)
+		}
+	} else {
+		privateRouteTable, err := ec2.NewRouteTable(ctx, component.childName(args.PrivateRouteTableName), &ec2.RouteTableArgs{
+			VpcId: myVpc.ID(),
+			Tags: pulumi.StringMap{
+				"Name": pulumi.String("Private Route Table"),
+			},
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+		privateRouteTables = append(privateRouteTables, privateRouteTable)
+	}
+
+	// Isolated tiers get a route table of their own with no routes out
+	routeTables := map[string]*ec2.RouteTable{
+		TierPublic: publicRouteTable,
+	}
+	for _, tier := range args.Tiers {
+		if tier.Kind != TierIsolated {
+			continue
+		}
+		routeTables[TierIsolated], err = ec2.NewRouteTable(ctx, component.childName("isolatedRouteTable"), &ec2.RouteTableArgs{
+			VpcId: myVpc.ID(),
+			Tags: pulumi.StringMap{
+				"Name": pulumi.String("Isolated Route Table"),
+			},
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	// NAT lives in the first public tier's subnets
+	var natTier string
+	for _, tier := range args.Tiers {
+		if tier.Kind == TierPublic {
+			natTier = tier.Name
+			break
+		}
+	}
+	var natSubnetIDs []pulumi.IDOutput
+
+	// Create Subnets
+	for _, plan := range plans {
+		subnetName := plan.Tier.Name + "Subnet-" + plan.AvailabilityZone
+
+		subnet, err := ec2.NewSubnet(ctx, component.childName(subnetName), &ec2.SubnetArgs{
+			VpcId:            myVpc.ID(),
+			CidrBlock:        pulumi.String(plan.CidrBlock),
+			AvailabilityZone: pulumi.String(plan.AvailabilityZone),
+			Tags: pulumi.StringMap{
+				"Name": pulumi.String(subnetName),
+			},
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+		routeTable := routeTables[plan.Tier.Kind]
+		if plan.Tier.Kind == TierPrivate {
+			routeTable = privateRouteTables[0]
+			if args.NatMode == NatPerAz {
+				routeTable = privateRouteTables[plan.AzIndex]
+			}
+		}
+		_, err = ec2.NewRouteTableAssociation(ctx, component.childName(fmt.Sprintf("%sSubnet%d-RouteTableAssociation", plan.Tier.Name, plan.AzIndex+1)), &ec2.RouteTableAssociationArgs{
+			SubnetId:     subnet.ID(),
+			RouteTableId: routeTable.ID(),
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+		if plan.Tier.Name == natTier {
+			natSubnetIDs = append(natSubnetIDs, subnet.ID())
+		}
+
+		component.TierSubnetIDs[plan.Tier.Name] = append(component.TierSubnetIDs[plan.Tier.Name], subnet.ID())
+		switch plan.Tier.Kind {
+		case TierPublic:
+			component.PublicSubnetIDs = append(component.PublicSubnetIDs, subnet.ID())
+		case TierPrivate:
+			component.PrivateSubnetIDs = append(component.PrivateSubnetIDs, subnet.ID())
+		case TierIsolated:
+			component.IsolatedSubnetIDs = append(component.IsolatedSubnetIDs, subnet.ID())
+		}
+	}
+
+	// Public Route Creation
+	_, err = ec2.NewRoute(ctx, component.childName(args.PublicRouteName), &ec2.RouteArgs{
+		RouteTableId:         publicRouteTable.ID(),
+		DestinationCidrBlock: pulumi.String("0.0.0.0/0"),
+		GatewayId:            internetGateway.ID(),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = component.createNat(ctx, args, myVpc, natSubnetIDs, privateRouteTables, igwAttachment)
+	if err != nil {
+		return nil, err
+	}
+
+	component.VpcId = myVpc.ID()
+	component.VpcCidrBlock = myVpc.CidrBlock
+	component.PublicRouteTableId = publicRouteTable.ID()
+	component.RouteTableIDs = append(component.RouteTableIDs, publicRouteTable.ID())
+	for _, routeTable := range privateRouteTables {
+		component.PrivateRouteTableIDs = append(component.PrivateRouteTableIDs, routeTable.ID())
+		component.RouteTableIDs = append(component.RouteTableIDs, routeTable.ID())
+	}
+	if isolatedRouteTable, ok := routeTables[TierIsolated]; ok {
+		component.RouteTableIDs = append(component.RouteTableIDs, isolatedRouteTable.ID())
+	}
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"vpcId":             myVpc.ID(),
+		"publicSubnetIds":   StringIDs(component.PublicSubnetIDs),
+		"privateSubnetIds":  StringIDs(component.PrivateSubnetIDs),
+		"isolatedSubnetIds": StringIDs(component.IsolatedSubnetIDs),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
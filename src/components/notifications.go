@@ -0,0 +1,225 @@
+package components
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/dynamodb"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/iam"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/lambda"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/sns"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// NotificationsArgs configures the submission notification pipeline.
+type NotificationsArgs struct {
+	// LambdaCodePath is the zip archive holding the Lambda handler.
+	LambdaCodePath string
+	// GcpKey is the service account key the Lambda uploads to GCS with.
+	GcpKey pulumi.StringOutput
+	// GcpBucketName is the bucket submissions are uploaded to. When empty no
+	// Lambda is created and the topic has no subscribers.
+	GcpBucketName string
+	// MandrillKey is the mail API key; it is treated as a secret.
+	MandrillKey pulumi.StringInput
+}
+
+// Notifications is an SNS topic whose messages are handled by a Lambda that
+// records each delivery in a DynamoDB table. The Lambda is left out when no
+// GCP bucket is configured.
+type Notifications struct {
+	pulumi.ResourceState
+
+	TopicArn  pulumi.StringOutput
+	TableName pulumi.StringOutput
+	// FunctionArn is the ARN of the Lambda subscribed to the topic, if any.
+	FunctionArn pulumi.StringOutput
+}
+
+// NewNotifications creates the topic, the tracking table and the subscribed Lambda.
+func NewNotifications(ctx *pulumi.Context, name string, args *NotificationsArgs, opts ...pulumi.ResourceOption) (*Notifications, error) {
+	component := &Notifications{}
+	err := ctx.RegisterComponentResource(typePrefix+"Notifications", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	mysns, err := sns.NewTopic(ctx, "mySNSTopic", nil, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	mydynamodb, err := dynamodb.NewTable(ctx, "dynamotbl", &dynamodb.TableArgs{
+		Attributes: dynamodb.TableAttributeArray{
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("ID"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("Name"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("Email"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("DownloadStatus"),
+				Type: pulumi.String("S"),
+			},
+			&dynamodb.TableAttributeArgs{
+				Name: pulumi.String("UploadPath"),
+				Type: pulumi.String("S"),
+			},
+		},
+		HashKey:       pulumi.String("ID"),
+		ReadCapacity:  pulumi.Int(5),
+		WriteCapacity: pulumi.Int(5),
+		GlobalSecondaryIndexes: dynamodb.TableGlobalSecondaryIndexArray{
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("Name"),
+				ProjectionType: pulumi.String("ALL"),
+				ReadCapacity:   pulumi.Int(5),
+				WriteCapacity:  pulumi.Int(5),
+				HashKey:        pulumi.String("Name"),
+			},
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("Email"),
+				ProjectionType: pulumi.String("ALL"),
+				ReadCapacity:   pulumi.Int(5),
+				WriteCapacity:  pulumi.Int(5),
+				HashKey:        pulumi.String("Email"),
+			},
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("DownloadStatus"),
+				ProjectionType: pulumi.String("ALL"),
+				ReadCapacity:   pulumi.Int(5),
+				WriteCapacity:  pulumi.Int(5),
+				HashKey:        pulumi.String("DownloadStatus"),
+			},
+			&dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("UploadPath"),
+				ProjectionType: pulumi.String("ALL"),
+				ReadCapacity:   pulumi.Int(5),
+				WriteCapacity:  pulumi.Int(5),
+				HashKey:        pulumi.String("UploadPath"),
+			},
+		},
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a bucket to upload to, nothing handles the topic's messages
+	if args.GcpBucketName != "" {
+		if err := component.createHandler(ctx, args, mysns, mydynamodb); err != nil {
+			return nil, err
+		}
+	}
+
+	component.TopicArn = mysns.Arn
+	component.TableName = mydynamodb.Name
+	outputs := pulumi.Map{
+		"topicArn":  mysns.Arn,
+		"tableName": mydynamodb.Name,
+	}
+	if args.GcpBucketName != "" {
+		outputs["functionArn"] = component.FunctionArn
+	}
+	if err := ctx.RegisterResourceOutputs(component, outputs); err != nil {
+		return nil, err
+	}
+	return component, nil
+}
+
+// createHandler creates the Lambda that uploads each submission to the GCP
+// bucket and mails the result, and subscribes it to topic.
+func (component *Notifications) createHandler(ctx *pulumi.Context, args *NotificationsArgs, topic *sns.Topic,
+	table *dynamodb.Table) error {
+	// Create IAM Role
+	roleLambda, err := iam.NewRole(ctx, "role-Lambda", &iam.RoleArgs{
+		AssumeRolePolicy: pulumi.String(`{
+			"Version": "2012-10-17",
+			"Statement": [
+				{
+					"Action": "sts:AssumeRole",
+					"Principal": {
+						"Service": "lambda.amazonaws.com"
+					},
+					"Effect": "Allow",
+					"Sid": ""
+				}
+			]
+		}`),
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	//Attach Lambda Access Policy
+	_, err = iam.NewRolePolicyAttachment(ctx, "rolePolicyAttachment-LambdaFullAccess-L", &iam.RolePolicyAttachmentArgs{
+		Role:      roleLambda.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AWSLambda_FullAccess"),
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	//Attach Lambda Access Policy
+	_, err = iam.NewRolePolicyAttachment(ctx, "rolePolicyAttachment-LambdaExecutionPolicy-L", &iam.RolePolicyAttachmentArgs{
+		Role:      roleLambda.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"),
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	//Attach DynamoDb Access Policy
+	_, err = iam.NewRolePolicyAttachment(ctx, "rolePolicyAttachment-DynamoDBAccess-L", &iam.RolePolicyAttachmentArgs{
+		Role:      roleLambda.Name,
+		PolicyArn: pulumi.String("arn:aws:iam::aws:policy/AmazonDynamoDBFullAccess"),
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	// create Lambda function
+	lf, err := lambda.NewFunction(ctx, "myLambdaFunction", &lambda.FunctionArgs{
+		Code:    pulumi.NewFileArchive(args.LambdaCodePath),
+		Handler: pulumi.String("main"), // suitable as per your function's start file
+		Role:    roleLambda.Arn,
+		Runtime: pulumi.String("go1.x"),
+		Timeout: pulumi.Int(60), // Modifiable as per your function's requirement
+		Environment: &lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap{
+				"GCPKEY":      pulumi.ToSecret(args.GcpKey).(pulumi.StringOutput),
+				"GCBUCKET":    pulumi.String(args.GcpBucketName),
+				"DYNAMOTB":    table.Name,
+				"MANDRILLKEY": pulumi.ToSecret(args.MandrillKey).(pulumi.StringOutput),
+			},
+		},
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	_, err = lambda.NewPermission(ctx, "myLambdaPermission", &lambda.PermissionArgs{
+		Action:      pulumi.String("lambda:InvokeFunction"),
+		Function:    lf.Name,
+		Principal:   pulumi.String("sns.amazonaws.com"),
+		SourceArn:   topic.Arn,
+		StatementId: pulumi.String("MyStatementId"),
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	_, err = sns.NewTopicSubscription(ctx, "mySubscription", &sns.TopicSubscriptionArgs{
+		Endpoint: lf.Arn,
+		Protocol: pulumi.String("lambda"),
+		Topic:    topic.Arn,
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	component.FunctionArn = lf.Arn
+	return nil
+}
@@ -0,0 +1,70 @@
+package components
+
+import (
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/autoscaling"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// InstanceRefreshSettings controls the rolling replacement of the app
+// instances whenever the launch template changes, e.g. on a new AMI.
+type InstanceRefreshSettings struct {
+	// Enabled turns rolling refreshes on; true when unset.
+	Enabled *bool `json:"enabled"`
+	// MinHealthyPercentage of the group keeps serving during a refresh; 90
+	// when unset. A group of one instance is down while it is replaced.
+	MinHealthyPercentage *int `json:"minHealthyPercentage"`
+	// InstanceWarmup is how long, in seconds, a new instance gets before it
+	// counts as healthy; 300 when unset.
+	InstanceWarmup *int `json:"instanceWarmup"`
+	// CheckpointPercentages pause the refresh for CheckpointDelay seconds,
+	// 300 when unset, once that share of instances is replaced; the last
+	// must be 100.
+	CheckpointPercentages []int `json:"checkpointPercentages"`
+	CheckpointDelay       *int  `json:"checkpointDelay"`
+}
+
+// WithDefaults fills in the settings left unset.
+func (s InstanceRefreshSettings) WithDefaults() InstanceRefreshSettings {
+	if s.Enabled == nil {
+		enabled := true
+		s.Enabled = &enabled
+	}
+	if s.MinHealthyPercentage == nil {
+		percentage := 90
+		s.MinHealthyPercentage = &percentage
+	}
+	if s.InstanceWarmup == nil {
+		warmup := 300
+		s.InstanceWarmup = &warmup
+	}
+	if len(s.CheckpointPercentages) > 0 && s.CheckpointDelay == nil {
+		delay := 300
+		s.CheckpointDelay = &delay
+	}
+	return s
+}
+
+// args returns the group's instance refresh block, or nil when disabled.
+func (s InstanceRefreshSettings) args() *autoscaling.GroupInstanceRefreshArgs {
+	s = s.WithDefaults()
+	if !*s.Enabled {
+		return nil
+	}
+	preferences := &autoscaling.GroupInstanceRefreshPreferencesArgs{
+		MinHealthyPercentage: pulumi.Int(*s.MinHealthyPercentage),
+		InstanceWarmup:       pulumi.String(strconv.Itoa(*s.InstanceWarmup)),
+	}
+	if len(s.CheckpointPercentages) > 0 {
+		preferences.CheckpointPercentages = pulumi.ToIntArray(s.CheckpointPercentages)
+		preferences.CheckpointDelay = pulumi.String(strconv.Itoa(*s.CheckpointDelay))
+	}
+	// A new launch template version always triggers a refresh; tag changes
+	// should as well, since they propagate to the instances
+	return &autoscaling.GroupInstanceRefreshArgs{
+		Strategy:    pulumi.String("Rolling"),
+		Preferences: preferences,
+		Triggers:    pulumi.StringArray{pulumi.String("tag")},
+	}
+}
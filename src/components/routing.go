@@ -0,0 +1,81 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/lb"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Service is an application port served behind the load balancer for the
+// requests matching its host and path patterns.
+type Service struct {
+	// Name prefixes the service's target group and listener rule.
+	Name string `json:"name"`
+	Port int    `json:"port"`
+	// TargetGroupSettings are read from the same object, e.g.
+	// "healthCheckPath" or "stickinessDuration".
+	TargetGroupSettings
+	// HostPatterns and PathPatterns, e.g. "admin.example.com" or "/api/*",
+	// must all match; at least one pattern is needed.
+	HostPatterns []string `json:"hostPatterns"`
+	PathPatterns []string `json:"pathPatterns"`
+	// Priority orders the listener rules, lowest first; unique per listener.
+	Priority int `json:"priority"`
+}
+
+// MaxServiceConditionValues is the most host and path patterns an ALB
+// listener rule accepts in total.
+const MaxServiceConditionValues = 5
+
+// createServiceRoutes adds a target group and an HTTPS listener rule for each
+// of args.Services. Requests matching none of them reach the default target
+// group.
+func (component *LoadBalancer) createServiceRoutes(ctx *pulumi.Context, args *LoadBalancerArgs, listener *lb.Listener) error {
+	component.ServiceTargetGroupArns = map[string]pulumi.StringOutput{}
+	for _, service := range args.Services {
+		targetGroup, err := newTargetGroup(ctx, component, service.Name+"TargetGroup", service.Port,
+			args.VpcId, service.TargetGroupSettings, pulumi.StringMap{
+				"Service": pulumi.String(service.Name),
+			})
+		if err != nil {
+			return err
+		}
+
+		var conditions lb.ListenerRuleConditionArray
+		if len(service.HostPatterns) > 0 {
+			conditions = append(conditions, &lb.ListenerRuleConditionArgs{
+				HostHeader: &lb.ListenerRuleConditionHostHeaderArgs{
+					Values: pulumi.ToStringArray(service.HostPatterns),
+				},
+			})
+		}
+		if len(service.PathPatterns) > 0 {
+			conditions = append(conditions, &lb.ListenerRuleConditionArgs{
+				PathPattern: &lb.ListenerRuleConditionPathPatternArgs{
+					Values: pulumi.ToStringArray(service.PathPatterns),
+				},
+			})
+		}
+		if len(conditions) == 0 {
+			return fmt.Errorf("service %s needs a host or path pattern", service.Name)
+		}
+
+		_, err = lb.NewListenerRule(ctx, component.childName(service.Name+"ListenerRule"), &lb.ListenerRuleArgs{
+			ListenerArn: listener.Arn,
+			Priority:    pulumi.Int(service.Priority),
+			Actions: lb.ListenerRuleActionArray{
+				&lb.ListenerRuleActionArgs{
+					Type:           pulumi.String("forward"),
+					TargetGroupArn: targetGroup.Arn,
+				},
+			},
+			Conditions: conditions,
+		}, childOpts(component)...)
+		if err != nil {
+			return err
+		}
+		component.ServiceTargetGroupArns[service.Name] = targetGroup.Arn
+	}
+	return nil
+}
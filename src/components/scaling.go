@@ -0,0 +1,307 @@
+package components
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/autoscaling"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// Scaling policies for ScalingSettings.Policy.
+const (
+	// ScalingPolicySimple adds or removes one instance when average CPU
+	// crosses 5% or 3%.
+	ScalingPolicySimple = "simple"
+	// ScalingPolicyTargetCpu keeps average CPU near TargetValue percent.
+	ScalingPolicyTargetCpu = "targetCpu"
+	// ScalingPolicyTargetRequests keeps the load balancer requests per
+	// instance near TargetValue.
+	ScalingPolicyTargetRequests = "targetRequests"
+	// ScalingPolicyStep adjusts capacity by the step whose CPU band the
+	// average falls in.
+	ScalingPolicyStep = "step"
+)
+
+// ScalingSettings picks how the app tier's autoscaling group scales.
+type ScalingSettings struct {
+	// Policy is one of the ScalingPolicy* values; simple when empty.
+	Policy      string  `json:"policy"`
+	TargetValue float64 `json:"targetValue"`
+	// Steps are the CPU bands of ScalingPolicyStep.
+	Steps []ScalingStep `json:"steps"`
+	// Schedules resize the group at set times whatever the policy.
+	Schedules []ScheduledScaling `json:"schedules"`
+}
+
+// ScalingStep changes capacity by Adjustment while average CPU is in
+// [From, To). Scale-out steps have a positive Adjustment and the highest
+// leaves To unset; scale-in steps are negative and the lowest leaves From unset.
+type ScalingStep struct {
+	From       *float64 `json:"from"`
+	To         *float64 `json:"to"`
+	Adjustment int      `json:"adjustment"`
+}
+
+// ScheduledScaling sets the group's size on a cron Recurrence, e.g. scaling a
+// dev stack to zero at night. Unset sizes are left unchanged.
+type ScheduledScaling struct {
+	Name       string `json:"name"`
+	Recurrence string `json:"recurrence"`
+	// TimeZone is an IANA name such as "Europe/Berlin"; UTC when empty.
+	TimeZone        string `json:"timeZone"`
+	MinSize         *int   `json:"minSize"`
+	MaxSize         *int   `json:"maxSize"`
+	DesiredCapacity *int   `json:"desiredCapacity"`
+}
+
+// SplitScalingSteps separates steps into scale-out bands, lowest first, and
+// scale-in bands, lowest first, checking that each side covers a contiguous
+// range open towards its extreme.
+func SplitScalingSteps(steps []ScalingStep) (out, in []ScalingStep, err error) {
+	for _, step := range steps {
+		switch {
+		case step.Adjustment > 0:
+			if step.From == nil {
+				return nil, nil, fmt.Errorf("scale-out step of %+d needs a lower CPU bound (from)", step.Adjustment)
+			}
+			out = append(out, step)
+		case step.Adjustment < 0:
+			if step.To == nil {
+				return nil, nil, fmt.Errorf("scale-in step of %+d needs an upper CPU bound (to)", step.Adjustment)
+			}
+			in = append(in, step)
+		default:
+			return nil, nil, fmt.Errorf("steps must not have a zero adjustment")
+		}
+		if step.From != nil && step.To != nil && *step.From >= *step.To {
+			return nil, nil, fmt.Errorf("step from %v to %v is empty", *step.From, *step.To)
+		}
+	}
+	if len(out) == 0 || len(in) == 0 {
+		return nil, nil, fmt.Errorf("steps need at least one scale-out (positive) and one scale-in (negative) adjustment")
+	}
+
+	sort.Slice(out, func(i, j int) bool { return *out[i].From < *out[j].From })
+	for i, step := range out {
+		last := i == len(out)-1
+		switch {
+		case last && step.To != nil:
+			return nil, nil, fmt.Errorf("the highest scale-out step must not set an upper bound (to)")
+		case !last && (step.To == nil || *step.To != *out[i+1].From):
+			return nil, nil, fmt.Errorf("scale-out step from %v must end where the next one starts, at %v", *step.From, *out[i+1].From)
+		}
+	}
+	sort.Slice(in, func(i, j int) bool { return *in[i].To < *in[j].To })
+	for i, step := range in {
+		switch {
+		case i == 0 && step.From != nil:
+			return nil, nil, fmt.Errorf("the lowest scale-in step must not set a lower bound (from)")
+		case i > 0 && (step.From == nil || *step.From != *in[i-1].To):
+			return nil, nil, fmt.Errorf("scale-in step to %v must start where the previous one ends, at %v", *step.To, *in[i-1].To)
+		}
+	}
+	if inThreshold, outThreshold := *in[len(in)-1].To, *out[0].From; inThreshold > outThreshold {
+		return nil, nil, fmt.Errorf("scale-in steps up to %v overlap scale-out steps from %v", inThreshold, outThreshold)
+	}
+	return out, in, nil
+}
+
+// createScaling attaches the scaling policy and scheduled actions of
+// args.Scaling to group's asg.
+func (component *AppTier) createScaling(ctx *pulumi.Context, args *AppTierArgs, group ColorGroup, asg *autoscaling.Group) error {
+	suffix := group.suffix()
+	var err error
+	switch args.Scaling.Policy {
+	case "", ScalingPolicySimple:
+		err = component.createSimpleScaling(ctx, asg, suffix)
+	case ScalingPolicyTargetCpu:
+		err = component.createTargetTracking(ctx, asg, suffix, args.Scaling.TargetValue, &autoscaling.PolicyTargetTrackingConfigurationPredefinedMetricSpecificationArgs{
+			PredefinedMetricType: pulumi.String("ASGAverageCPUUtilization"),
+		})
+	case ScalingPolicyTargetRequests:
+		err = component.createTargetTracking(ctx, asg, suffix, args.Scaling.TargetValue, &autoscaling.PolicyTargetTrackingConfigurationPredefinedMetricSpecificationArgs{
+			PredefinedMetricType: pulumi.String("ALBRequestCountPerTarget"),
+			ResourceLabel:        group.RequestCountResourceLabel,
+		})
+	case ScalingPolicyStep:
+		err = component.createStepScaling(ctx, asg, suffix, args.Scaling.Steps)
+	default:
+		err = fmt.Errorf("unknown scaling policy %q", args.Scaling.Policy)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range args.Scaling.Schedules {
+		scheduleArgs := &autoscaling.ScheduleArgs{
+			AutoscalingGroupName: asg.Name,
+			ScheduledActionName:  pulumi.String(schedule.Name),
+			Recurrence:           pulumi.String(schedule.Recurrence),
+			// -1 leaves a size as it is; the provider defaults to 0
+			MinSize:         pulumi.Int(sizeOrUnchanged(schedule.MinSize)),
+			MaxSize:         pulumi.Int(sizeOrUnchanged(schedule.MaxSize)),
+			DesiredCapacity: pulumi.Int(sizeOrUnchanged(schedule.DesiredCapacity)),
+		}
+		if schedule.TimeZone != "" {
+			scheduleArgs.TimeZone = pulumi.String(schedule.TimeZone)
+		}
+		_, err := autoscaling.NewSchedule(ctx, component.childName("schedule-"+schedule.Name+suffix), scheduleArgs, childOpts(component)...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sizeOrUnchanged maps an unset scheduled size to -1, which keeps the
+// group's current value.
+func sizeOrUnchanged(size *int) int {
+	if size == nil {
+		return -1
+	}
+	return *size
+}
+
+// createSimpleScaling adds the original pair of one-instance policies and
+// their CPU alarms. suffix is appended to the resource names.
+func (component *AppTier) createSimpleScaling(ctx *pulumi.Context, asg *autoscaling.Group, suffix string) error {
+	// Create AutoScaling Policy - ScaleUp
+	policyUp, err := autoscaling.NewPolicy(ctx, component.childName("scaleUp"+suffix), &autoscaling.PolicyArgs{
+		AdjustmentType:       pulumi.String("ChangeInCapacity"),
+		ScalingAdjustment:    pulumi.Int(1),
+		PolicyType:           pulumi.String("SimpleScaling"),
+		AutoscalingGroupName: asg.Name,
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	_, err = cloudwatch.NewMetricAlarm(ctx, component.childName("cpuHigh"+suffix), &cloudwatch.MetricAlarmArgs{
+		ComparisonOperator: pulumi.String("GreaterThanThreshold"),
+		EvaluationPeriods:  pulumi.Int(2),
+		MetricName:         pulumi.String("CPUUtilization"),
+		Namespace:          pulumi.String("AWS/EC2"),
+		Period:             pulumi.Int(60),
+		Statistic:          pulumi.String("Average"),
+		Threshold:          pulumi.Float64(5.0),
+		Dimensions: pulumi.StringMap{
+			"AutoScalingGroupName": asg.Name,
+		},
+		AlarmDescription: pulumi.String("This metric monitors ec2 cpu utilization and scales up"),
+		AlarmActions: pulumi.Array{
+			policyUp.Arn,
+		},
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	// Create AutoScaling Policy - ScaleDn
+	policyDn, err := autoscaling.NewPolicy(ctx, component.childName("scaleDn"+suffix), &autoscaling.PolicyArgs{
+		AdjustmentType:       pulumi.String("ChangeInCapacity"),
+		ScalingAdjustment:    pulumi.Int(-1),
+		PolicyType:           pulumi.String("SimpleScaling"),
+		AutoscalingGroupName: asg.Name,
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	_, err = cloudwatch.NewMetricAlarm(ctx, component.childName("cpuLow"+suffix), &cloudwatch.MetricAlarmArgs{
+		ComparisonOperator: pulumi.String("LessThanThreshold"),
+		EvaluationPeriods:  pulumi.Int(2),
+		MetricName:         pulumi.String("CPUUtilization"),
+		Namespace:          pulumi.String("AWS/EC2"),
+		Period:             pulumi.Int(60),
+		Statistic:          pulumi.String("Average"),
+		Threshold:          pulumi.Float64(3.0),
+		Dimensions: pulumi.StringMap{
+			"AutoScalingGroupName": asg.Name,
+		},
+		AlarmDescription: pulumi.String("This metric monitors ec2 cpu utilization and scales up"),
+		AlarmActions: pulumi.Array{
+			policyDn.Arn,
+		},
+	}, childOpts(component)...)
+	return err
+}
+
+// createTargetTracking adds a target tracking policy holding metric at targetValue.
+func (component *AppTier) createTargetTracking(ctx *pulumi.Context, asg *autoscaling.Group, suffix string, targetValue float64,
+	metric *autoscaling.PolicyTargetTrackingConfigurationPredefinedMetricSpecificationArgs) error {
+	_, err := autoscaling.NewPolicy(ctx, component.childName("targetTracking"+suffix), &autoscaling.PolicyArgs{
+		AutoscalingGroupName: asg.Name,
+		PolicyType:           pulumi.String("TargetTrackingScaling"),
+		TargetTrackingConfiguration: &autoscaling.PolicyTargetTrackingConfigurationArgs{
+			PredefinedMetricSpecification: metric,
+			TargetValue:                   pulumi.Float64(targetValue),
+		},
+	}, childOpts(component)...)
+	return err
+}
+
+// createStepScaling adds a scale-out and a scale-in step policy, each
+// triggered by a CPU alarm at the edge of its bands.
+func (component *AppTier) createStepScaling(ctx *pulumi.Context, asg *autoscaling.Group, suffix string, steps []ScalingStep) error {
+	out, in, err := SplitScalingSteps(steps)
+	if err != nil {
+		return err
+	}
+	err = component.createStepPolicy(ctx, asg, "stepScaleOut"+suffix, "GreaterThanOrEqualToThreshold", *out[0].From, out)
+	if err != nil {
+		return err
+	}
+	return component.createStepPolicy(ctx, asg, "stepScaleIn"+suffix, "LessThanThreshold", *in[len(in)-1].To, in)
+}
+
+// createStepPolicy creates the step policy name and the alarm, comparing
+// average CPU to threshold, that runs it. Step bounds are relative to threshold.
+func (component *AppTier) createStepPolicy(ctx *pulumi.Context, asg *autoscaling.Group, name, comparison string,
+	threshold float64, steps []ScalingStep) error {
+	bound := func(v *float64) pulumi.StringPtrInput {
+		if v == nil {
+			return nil
+		}
+		return pulumi.String(strconv.FormatFloat(*v-threshold, 'f', -1, 64))
+	}
+	var adjustments autoscaling.PolicyStepAdjustmentArray
+	for _, step := range steps {
+		adjustments = append(adjustments, &autoscaling.PolicyStepAdjustmentArgs{
+			MetricIntervalLowerBound: bound(step.From),
+			MetricIntervalUpperBound: bound(step.To),
+			ScalingAdjustment:        pulumi.Int(step.Adjustment),
+		})
+	}
+	policy, err := autoscaling.NewPolicy(ctx, component.childName(name), &autoscaling.PolicyArgs{
+		AutoscalingGroupName:    asg.Name,
+		PolicyType:              pulumi.String("StepScaling"),
+		AdjustmentType:          pulumi.String("ChangeInCapacity"),
+		MetricAggregationType:   pulumi.String("Average"),
+		EstimatedInstanceWarmup: pulumi.Int(60),
+		StepAdjustments:         adjustments,
+	}, childOpts(component)...)
+	if err != nil {
+		return err
+	}
+
+	_, err = cloudwatch.NewMetricAlarm(ctx, component.childName(name+"Alarm"), &cloudwatch.MetricAlarmArgs{
+		ComparisonOperator: pulumi.String(comparison),
+		EvaluationPeriods:  pulumi.Int(2),
+		MetricName:         pulumi.String("CPUUtilization"),
+		Namespace:          pulumi.String("AWS/EC2"),
+		Period:             pulumi.Int(60),
+		Statistic:          pulumi.String("Average"),
+		Threshold:          pulumi.Float64(threshold),
+		Dimensions: pulumi.StringMap{
+			"AutoScalingGroupName": asg.Name,
+		},
+		AlarmDescription: pulumi.String(fmt.Sprintf("Runs %s when average CPU is %s %v%%", name, comparison, threshold)),
+		AlarmActions: pulumi.Array{
+			policy.Arn,
+		},
+	}, childOpts(component)...)
+	return err
+}
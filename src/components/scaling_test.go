@@ -0,0 +1,63 @@
+package components
+
+import (
+	"strings"
+	"testing"
+)
+
+func cpu(v float64) *float64 { return &v }
+
+func TestSplitScalingStepsOrdersBands(t *testing.T) {
+	steps := []ScalingStep{
+		{From: cpu(80), Adjustment: 3},
+		{To: cpu(20), Adjustment: -1},
+		{From: cpu(60), To: cpu(80), Adjustment: 1},
+		{From: cpu(20), To: cpu(30), Adjustment: -1},
+	}
+	out, in, err := SplitScalingSteps(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || *out[0].From != 60 || out[1].To != nil {
+		t.Errorf("scale-out bands = %+v, want 60-80 then 80 and up", out)
+	}
+	if len(in) != 2 || in[0].From != nil || *in[1].To != 30 {
+		t.Errorf("scale-in bands = %+v, want below 20 then 20-30", in)
+	}
+}
+
+func TestSplitScalingStepsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []ScalingStep
+		want  string
+	}{
+		{"no scale-in", []ScalingStep{{From: cpu(60), Adjustment: 1}}, "at least one scale-out"},
+		{"zero adjustment", []ScalingStep{{From: cpu(60)}}, "zero adjustment"},
+		{"gap", []ScalingStep{
+			{From: cpu(60), To: cpu(70), Adjustment: 1},
+			{From: cpu(75), Adjustment: 2},
+			{To: cpu(20), Adjustment: -1},
+		}, "must end where the next one starts"},
+		{"bounded top", []ScalingStep{
+			{From: cpu(60), To: cpu(90), Adjustment: 1},
+			{To: cpu(20), Adjustment: -1},
+		}, "highest scale-out step"},
+		{"bounded bottom", []ScalingStep{
+			{From: cpu(60), Adjustment: 1},
+			{From: cpu(5), To: cpu(20), Adjustment: -1},
+		}, "lowest scale-in step"},
+		{"overlap", []ScalingStep{
+			{From: cpu(40), Adjustment: 1},
+			{To: cpu(50), Adjustment: -1},
+		}, "overlap"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := SplitScalingSteps(tt.steps)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
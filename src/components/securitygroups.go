@@ -0,0 +1,159 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// SecurityGroupsArgs configures the firewall rules between the tiers.
+type SecurityGroupsArgs struct {
+	// NamePrefix prefixes the child resource names; see naming.
+	NamePrefix string
+
+	VpcId pulumi.IDOutput
+	// LoadBalancerPorts are opened on the load balancer to the internet.
+	LoadBalancerPorts []int
+	// AppPorts are opened on the app tier to traffic from the load balancer.
+	AppPorts []int
+	// DatabasePort is opened on the database tier to traffic from the app tier.
+	DatabasePort int
+}
+
+// SecurityGroups holds one security group per tier, chained so that the load
+// balancer reaches the app tier and the app tier reaches the database.
+type SecurityGroups struct {
+	pulumi.ResourceState
+	naming
+
+	LoadBalancerId pulumi.IDOutput
+	AppId          pulumi.IDOutput
+	DatabaseId     pulumi.IDOutput
+}
+
+// NewSecurityGroups creates the load balancer, app and database security groups.
+func NewSecurityGroups(ctx *pulumi.Context, name string, args *SecurityGroupsArgs, opts ...pulumi.ResourceOption) (*SecurityGroups, error) {
+	component := &SecurityGroups{naming: naming{args.NamePrefix}}
+	err := ctx.RegisterComponentResource(typePrefix+"SecurityGroups", name, component, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create load balancer security group
+	var lbIngress ec2.SecurityGroupIngressArray
+	for _, port := range args.LoadBalancerPorts {
+		lbIngress = append(lbIngress, ec2.SecurityGroupIngressArgs{
+			FromPort: pulumi.Int(port),
+			ToPort:   pulumi.Int(port),
+			Protocol: pulumi.String("tcp"),
+			CidrBlocks: pulumi.StringArray{
+				pulumi.String("0.0.0.0/0"),
+			},
+		})
+	}
+	lbSecurityGroup, err := ec2.NewSecurityGroup(ctx, component.childName("lbSecurityGroup"), &ec2.SecurityGroupArgs{
+		VpcId:   args.VpcId,
+		Ingress: lbIngress,
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ec2.NewSecurityGroupRule(ctx, component.childName("outboundruleLoadBalancer"), &ec2.SecurityGroupRuleArgs{
+		Type:     pulumi.String("egress"),
+		FromPort: pulumi.Int(0),
+		ToPort:   pulumi.Int(65535),
+		Protocol: pulumi.String("tcp"),
+		CidrBlocks: pulumi.StringArray{
+			pulumi.String("0.0.0.0/0"),
+		},
+		SecurityGroupId: lbSecurityGroup.ID(),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create an application security group for app deployment
+	appSecGroup, err := ec2.NewSecurityGroup(ctx, component.childName("application security group"), &ec2.SecurityGroupArgs{
+		Description: pulumi.String("Allow TLS inbound traffic"),
+		VpcId:       args.VpcId,
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add an ingress rule for each port in the list
+	for i, port := range args.AppPorts {
+		_, err := ec2.NewSecurityGroupRule(ctx, component.childName(fmt.Sprintf("ingressRule-%d", i)), &ec2.SecurityGroupRuleArgs{
+			Type:                  pulumi.String("ingress"),
+			FromPort:              pulumi.Int(port),
+			ToPort:                pulumi.Int(port),
+			Protocol:              pulumi.String("tcp"),
+			SourceSecurityGroupId: lbSecurityGroup.ID(),
+			SecurityGroupId:       appSecGroup.ID(),
+		}, childOpts(component)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = ec2.NewSecurityGroupRule(ctx, component.childName("outboundruleApp"), &ec2.SecurityGroupRuleArgs{
+		Type:     pulumi.String("egress"),
+		FromPort: pulumi.Int(0),
+		ToPort:   pulumi.Int(65535),
+		Protocol: pulumi.String("tcp"),
+		CidrBlocks: pulumi.StringArray{
+			pulumi.String("0.0.0.0/0"),
+		},
+		SecurityGroupId: appSecGroup.ID(),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create DB Security Group for RDS
+	dbSecurityGroup, err := ec2.NewSecurityGroup(ctx, component.childName("dbSecurityGroup"), &ec2.SecurityGroupArgs{
+		Description: pulumi.String("DB Security Group"),
+		VpcId:       args.VpcId,
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ec2.NewSecurityGroupRule(ctx, component.childName("dbSecurityGroupRule"), &ec2.SecurityGroupRuleArgs{
+		Type:                  pulumi.String("ingress"),
+		FromPort:              pulumi.Int(args.DatabasePort),
+		ToPort:                pulumi.Int(args.DatabasePort),
+		Protocol:              pulumi.String("tcp"),
+		SourceSecurityGroupId: appSecGroup.ID(),
+		SecurityGroupId:       dbSecurityGroup.ID(),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ec2.NewSecurityGroupRule(ctx, component.childName("dbSecurityGroupOutboundRule"), &ec2.SecurityGroupRuleArgs{
+		Type:                  pulumi.String("egress"),
+		FromPort:              pulumi.Int(0),
+		ToPort:                pulumi.Int(65535),
+		Protocol:              pulumi.String("tcp"),
+		SourceSecurityGroupId: appSecGroup.ID(),
+		SecurityGroupId:       dbSecurityGroup.ID(),
+	}, childOpts(component)...)
+	if err != nil {
+		return nil, err
+	}
+
+	component.LoadBalancerId = lbSecurityGroup.ID()
+	component.AppId = appSecGroup.ID()
+	component.DatabaseId = dbSecurityGroup.ID()
+	err = ctx.RegisterResourceOutputs(component, pulumi.Map{
+		"loadBalancerId": lbSecurityGroup.ID(),
+		"appId":          appSecGroup.ID(),
+		"databaseId":     dbSecurityGroup.ID(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return component, nil
+}
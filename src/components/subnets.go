@@ -0,0 +1,96 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/dspinhirne/netaddr-go"
+)
+
+// Subnet tier kinds, which decide how a tier's subnets are routed.
+const (
+	// TierPublic subnets route to the internet gateway.
+	TierPublic = "public"
+	// TierPrivate subnets share the private route table.
+	TierPrivate = "private"
+	// TierIsolated subnets have a route table with no routes out of the VPC.
+	TierIsolated = "isolated"
+)
+
+// SubnetTier is one row of subnets, created once in every availability zone.
+type SubnetTier struct {
+	// Name prefixes the tier's resource names, e.g. "public" gives
+	// publicSubnet-us-east-1a.
+	Name string `json:"name"`
+	// Kind is one of TierPublic, TierPrivate or TierIsolated.
+	Kind string `json:"kind"`
+	// PrefixLength is the size of each of the tier's subnets, e.g. 24.
+	PrefixLength uint `json:"prefixLength"`
+}
+
+// SubnetPlan is the CIDR chosen for one tier in one availability zone.
+type SubnetPlan struct {
+	Tier SubnetTier
+	// AzIndex is the position of AvailabilityZone in the zone list.
+	AzIndex          int
+	AvailabilityZone string
+	CidrBlock        string
+}
+
+// PlanSubnets lays out every tier in every zone, zone by zone, packing the
+// subnets into cidrBlock in order. Each subnet is aligned to its own size, and
+// the first block of the first tier's size is left unused, as it always has
+// been, so existing stacks keep their CIDRs.
+func PlanSubnets(cidrBlock string, zones []string, tiers []SubnetTier) ([]SubnetPlan, error) {
+	vpcNet, err := netaddr.ParseIPv4Net(cidrBlock)
+	if err != nil {
+		return nil, err
+	}
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("at least one subnet tier is required")
+	}
+
+	vpcPrefix := vpcNet.Netmask().PrefixLen()
+	for _, tier := range tiers {
+		if tier.PrefixLength <= vpcPrefix || tier.PrefixLength > 32 {
+			return nil, fmt.Errorf("tier %s: prefix /%d does not fit inside the VPC's /%d",
+				tier.Name, tier.PrefixLength, vpcPrefix)
+		}
+	}
+
+	start := uint64(vpcNet.Network().Addr())
+	end := start + blockSize(vpcPrefix)
+	cursor := start + blockSize(tiers[0].PrefixLength)
+
+	var plans []SubnetPlan
+	for i, az := range zones {
+		for _, tier := range tiers {
+			size := blockSize(tier.PrefixLength)
+			cursor = (cursor + size - 1) / size * size
+			if cursor+size > end {
+				return nil, fmt.Errorf("%s has no room left for the %s subnet in %s", cidrBlock, tier.Name, az)
+			}
+			subnet, err := netaddr.NewIPv4Net(netaddr.NewIPv4(uint32(cursor)), mask(tier.PrefixLength))
+			if err != nil {
+				return nil, err
+			}
+			plans = append(plans, SubnetPlan{
+				Tier:             tier,
+				AzIndex:          i,
+				AvailabilityZone: az,
+				CidrBlock:        subnet.String(),
+			})
+			cursor += size
+		}
+	}
+	return plans, nil
+}
+
+// blockSize is the number of addresses in a subnet of the given prefix length.
+func blockSize(prefixLength uint) uint64 {
+	return 1 << (32 - prefixLength)
+}
+
+func mask(prefixLength uint) *netaddr.Mask32 {
+	m, _ := netaddr.NewMask32(prefixLength)
+	return m
+}
@@ -0,0 +1,88 @@
+package components
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanSubnetsKeepsLegacyLayout(t *testing.T) {
+	tiers := []SubnetTier{
+		{Name: "public", Kind: TierPublic, PrefixLength: 24},
+		{Name: "private", Kind: TierPrivate, PrefixLength: 24},
+	}
+	plans, err := PlanSubnets("10.2.0.0/16", []string{"a", "b", "c"}, tiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"10.2.1.0/24", "10.2.2.0/24", "10.2.3.0/24", "10.2.4.0/24", "10.2.5.0/24", "10.2.6.0/24"}
+	if len(plans) != len(want) {
+		t.Fatalf("got %d subnets, want %d", len(plans), len(want))
+	}
+	for i, plan := range plans {
+		if plan.CidrBlock != want[i] {
+			t.Errorf("subnet %d (%s in %s) = %s, want %s", i, plan.Tier.Name, plan.AvailabilityZone, plan.CidrBlock, want[i])
+		}
+	}
+}
+
+func TestPlanSubnetsMixedPrefixes(t *testing.T) {
+	tiers := []SubnetTier{
+		{Name: "public", Kind: TierPublic, PrefixLength: 24},
+		{Name: "private-app", Kind: TierPrivate, PrefixLength: 22},
+		{Name: "private-db", Kind: TierIsolated, PrefixLength: 26},
+	}
+	plans, err := PlanSubnets("10.0.0.0/16", []string{"a", "b"}, tiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"10.0.1.0/24", "10.0.4.0/22", "10.0.8.0/26",
+		"10.0.9.0/24", "10.0.12.0/22", "10.0.16.0/26",
+	}
+	for i, plan := range plans {
+		if plan.CidrBlock != want[i] {
+			t.Errorf("subnet %d (%s in %s) = %s, want %s", i, plan.Tier.Name, plan.AvailabilityZone, plan.CidrBlock, want[i])
+		}
+	}
+}
+
+func TestPlanSubnetsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidr  string
+		zones []string
+		tiers []SubnetTier
+		want  string
+	}{
+		{
+			name:  "no tiers",
+			cidr:  "10.0.0.0/16",
+			zones: []string{"a"},
+			want:  "at least one subnet tier",
+		},
+		{
+			name:  "tier larger than vpc",
+			cidr:  "10.0.0.0/24",
+			zones: []string{"a"},
+			tiers: []SubnetTier{{Name: "public", Kind: TierPublic, PrefixLength: 20}},
+			want:  "does not fit",
+		},
+		{
+			name:  "out of room",
+			cidr:  "10.0.0.0/24",
+			zones: []string{"a", "b"},
+			tiers: []SubnetTier{{Name: "public", Kind: TierPublic, PrefixLength: 26}, {Name: "private", Kind: TierPrivate, PrefixLength: 26}},
+			want:  "no room left for the private subnet in b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := PlanSubnets(tt.cidr, tt.zones, tt.tiers)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package components
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/lb"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// TargetGroupSettings tunes how a target group checks and drains its
+// instances. Zero values are replaced by WithDefaults.
+type TargetGroupSettings struct {
+	HealthCheckPath string `json:"healthCheckPath"`
+	// HealthCheckInterval and HealthCheckTimeout are in seconds; the timeout
+	// must be shorter than the interval.
+	HealthCheckInterval int `json:"healthCheckInterval"`
+	HealthCheckTimeout  int `json:"healthCheckTimeout"`
+	// HealthCheckMatcher lists the healthy HTTP codes, e.g. "200" or "200-299".
+	HealthCheckMatcher string `json:"healthCheckMatcher"`
+	// HealthyThreshold and UnhealthyThreshold are the consecutive checks
+	// needed to change an instance's state.
+	HealthyThreshold   int `json:"healthyThreshold"`
+	UnhealthyThreshold int `json:"unhealthyThreshold"`
+
+	// SlowStart ramps traffic to new instances over this many seconds; 0 is off.
+	SlowStart int `json:"slowStart"`
+	// DeregistrationDelay is how long, in seconds, a leaving instance keeps
+	// serving in-flight requests. nil means the default of 300.
+	DeregistrationDelay *int `json:"deregistrationDelay"`
+	// StickinessDuration pins clients to an instance with a load balancer
+	// cookie for this many seconds; 0 is off.
+	StickinessDuration int `json:"stickinessDuration"`
+}
+
+// WithDefaults fills in the settings left unset with the values the stack
+// has always used.
+func (s TargetGroupSettings) WithDefaults() TargetGroupSettings {
+	if s.HealthCheckPath == "" {
+		s.HealthCheckPath = "/healthz"
+	}
+	if s.HealthCheckInterval == 0 {
+		s.HealthCheckInterval = 30
+	}
+	if s.HealthCheckTimeout == 0 {
+		s.HealthCheckTimeout = 5
+	}
+	if s.HealthCheckMatcher == "" {
+		s.HealthCheckMatcher = "200"
+	}
+	if s.HealthyThreshold == 0 {
+		s.HealthyThreshold = 5
+	}
+	if s.UnhealthyThreshold == 0 {
+		s.UnhealthyThreshold = 2
+	}
+	if s.DeregistrationDelay == nil {
+		delay := 300
+		s.DeregistrationDelay = &delay
+	}
+	return s
+}
+
+// newTargetGroup creates an HTTP target group for port with the given settings.
+func newTargetGroup(ctx *pulumi.Context, component *LoadBalancer, name string, port int,
+	vpcId pulumi.IDOutput, settings TargetGroupSettings, tags pulumi.StringMap) (*lb.TargetGroup, error) {
+	settings = settings.WithDefaults()
+	targetGroupArgs := &lb.TargetGroupArgs{
+		Port:     pulumi.Int(port),
+		Protocol: pulumi.String("HTTP"),
+		VpcId:    vpcId,
+		HealthCheck: &lb.TargetGroupHealthCheckArgs{
+			Enabled:            pulumi.Bool(true),
+			Interval:           pulumi.Int(settings.HealthCheckInterval),
+			Path:               pulumi.String(settings.HealthCheckPath),
+			Timeout:            pulumi.Int(settings.HealthCheckTimeout),
+			Port:               pulumi.String("traffic-port"),
+			Protocol:           pulumi.String("HTTP"),
+			Matcher:            pulumi.String(settings.HealthCheckMatcher),
+			HealthyThreshold:   pulumi.Int(settings.HealthyThreshold),
+			UnhealthyThreshold: pulumi.Int(settings.UnhealthyThreshold),
+		},
+		SlowStart:           pulumi.Int(settings.SlowStart),
+		DeregistrationDelay: pulumi.Int(*settings.DeregistrationDelay),
+		Tags:                tags,
+	}
+	if component.elbNamePrefix != "" {
+		targetGroupArgs.NamePrefix = pulumi.String(component.elbNamePrefix)
+	}
+	// Stickiness is always sent, so turning it off disables it rather than
+	// leaving the target group's last setting in place
+	stickiness := &lb.TargetGroupStickinessArgs{
+		Enabled: pulumi.Bool(settings.StickinessDuration > 0),
+		Type:    pulumi.String("lb_cookie"),
+	}
+	if settings.StickinessDuration > 0 {
+		stickiness.CookieDuration = pulumi.Int(settings.StickinessDuration)
+	}
+	targetGroupArgs.Stickiness = stickiness
+	return lb.NewTargetGroup(ctx, component.childName(name), targetGroupArgs, childOpts(component)...)
+}
@@ -0,0 +1,92 @@
+package components
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+)
+
+// MaxUserDataBytes is the most user data EC2 accepts, before base64 encoding.
+const MaxUserDataBytes = 16 * 1024
+
+// AppSettings are the settings the app reads from /opt/dbconfig.yaml.
+type AppSettings struct {
+	DbHost string
+	DbPort int
+	DbName string
+	DbUser string
+	// DbSecretArn is the Secrets Manager secret holding the database
+	// password, fetched at boot so the password stays out of user data.
+	DbSecretArn string
+	SnsTopicArn string
+	// SnsRegion is the topic's region, where it must be published to.
+	SnsRegion string
+	// Region is where the instance runs and calls AWS APIs.
+	Region string
+	// LogGroup is the CloudWatch log group the app logs to.
+	LogGroup string
+}
+
+// dbPasswordPlaceholder stands in for the password in the written config
+// until set-db-password.py swaps in the one from Secrets Manager.
+const dbPasswordPlaceholder = "__DB_PASSWORD__"
+
+// userDataTemplate is a cloud-init document for the app instances. Files are
+// written whole, so running it again leaves the same config behind. Values
+// are rendered with the json function, which gives valid YAML and Python
+// strings.
+var userDataTemplate = template.Must(template.New("userdata").Funcs(template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}).Parse(`#cloud-config
+write_files:
+  - path: /opt/dbconfig.yaml
+    owner: csye6225:csye6225
+    permissions: "0664"
+    content: |
+      user: {{json .DbUser}}
+      password: ` + dbPasswordPlaceholder + `
+      host: {{json .DbHost}}
+      port: {{.DbPort}}
+      db: {{json .DbName}}
+      snsarn: {{json .SnsTopicArn}}
+      snsregion: {{json .SnsRegion}}
+      region: {{json .Region}}
+      loggroup: {{json .LogGroup}}
+  - path: /opt/set-db-password.py
+    permissions: "0700"
+    content: |
+      import json
+      import subprocess
+
+      secret = subprocess.check_output([
+          "aws", "secretsmanager", "get-secret-value",
+          "--region", {{json .Region}},
+          "--secret-id", {{json .DbSecretArn}},
+          "--query", "SecretString", "--output", "text",
+      ])
+      password = json.loads(secret)["password"]
+      with open("/opt/dbconfig.yaml") as f:
+          config = f.read()
+      with open("/opt/dbconfig.yaml", "w") as f:
+          f.write(config.replace("` + dbPasswordPlaceholder + `", json.dumps(password)))
+runcmd:
+  - [python3, /opt/set-db-password.py]
+  - [/opt/aws/amazon-cloudwatch-agent/bin/amazon-cloudwatch-agent-ctl, -a, fetch-config, -m, ec2, -c, "file:/opt/cloudwatch-config.json", -s]
+`))
+
+// RenderUserData renders the app instances' cloud-init user data and checks
+// it fits in MaxUserDataBytes.
+func RenderUserData(settings AppSettings) (string, error) {
+	var b bytes.Buffer
+	if err := userDataTemplate.Execute(&b, settings); err != nil {
+		return "", err
+	}
+	if b.Len() > MaxUserDataBytes {
+		return "", fmt.Errorf("user data is %d bytes, more than the %d EC2 accepts", b.Len(), MaxUserDataBytes)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,66 @@
+package components
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func testAppSettings() AppSettings {
+	return AppSettings{
+		DbHost:      "db.example.internal",
+		DbPort:      5432,
+		DbName:      "csye6225",
+		DbUser:      "csye6225",
+		DbSecretArn: "arn:aws:secretsmanager:us-west-2:123456789012:secret:db-password",
+		SnsTopicArn: "arn:aws:sns:us-east-1:123456789012:topic",
+		SnsRegion:   "us-east-1",
+		Region:      "us-west-2",
+		LogGroup:    "csye6225",
+	}
+}
+
+func TestRenderUserDataGolden(t *testing.T) {
+	got, err := RenderUserData(testAppSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "userdata.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("user data differs from %s; rerun with -update if intended:\n%s", golden, got)
+	}
+}
+
+func TestRenderUserDataQuotesValues(t *testing.T) {
+	settings := testAppSettings()
+	settings.DbName = `app: "prod"`
+	got, err := RenderUserData(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `db: "app: \"prod\""`) {
+		t.Errorf("database name is not quoted:\n%s", got)
+	}
+}
+
+func TestRenderUserDataRejectsOversize(t *testing.T) {
+	settings := testAppSettings()
+	settings.LogGroup = strings.Repeat("a", MaxUserDataBytes)
+	_, err := RenderUserData(settings)
+	if err == nil || !strings.Contains(err.Error(), "16384") {
+		t.Errorf("err = %v, want the 16384 byte limit exceeded", err)
+	}
+}
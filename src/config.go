@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+
+	"pulumi-infra-setup/components"
+)
+
+// defaultAzCount is the number of availability zones used when neither
+// azCount nor availabilityZones is set.
+const defaultAzCount = 3
+
+// Config holds the settings read from the active stack's Pulumi.<stack>.yaml.
+type Config struct {
+	Network struct {
+		CIDRBlockAddr                 string
+		VPCName                       string
+		InternetGateWayName           string
+		InternetGatewayAttachmentName string
+		PublicRouteTableName          string
+		PrivateRouteTableName         string
+		SubNet                        uint
+		PublicRouteName               string
+		SSHKeyName                    string
+		AmiName                       string
+		GcpBucketname                 string
+		// MandrillKey is the mail API key handed to the Lambda, kept secret.
+		MandrillKey pulumi.StringOutput
+		// DbPassword is the RDS master password, from a secret config value.
+		// When unset one is generated on the first deployment.
+		DbPassword pulumi.StringInput
+		// Ami selects the app instances' image; a lookup by name uses AmiName
+		// unless Ami.Name is set.
+		Ami components.AmiSettings
+
+		// AzCount is the number of zones to use; 0 means up to defaultAzCount.
+		AzCount int
+		// AvailabilityZones pins the zones explicitly and overrides AzCount.
+		AvailabilityZones []string
+		// SubnetTiers defaults to a public and a private tier of SubNet size.
+		SubnetTiers []components.SubnetTier
+		// NatMode is one of the components.Nat* modes; none by default.
+		NatMode         string
+		NatInstanceType string
+		// PrivateAppInstances places the app instances in the first private
+		// tier, with no public IPs, leaving only the load balancer public.
+		PrivateAppInstances bool
+		// GatewayEndpoints and InterfaceEndpoints name the AWS services,
+		// e.g. "dynamodb" or "sns", reached through VPC endpoints.
+		GatewayEndpoints   []string
+		InterfaceEndpoints []string
+
+		// DomainName is aliased to the load balancer in the hosted zone given
+		// by HostedZoneId, or looked up by HostedZoneName.
+		DomainName     string
+		HostedZoneId   string
+		HostedZoneName string
+		// CertificateArn is an existing ACM certificate for DomainName; with
+		// CreateCertificate one is requested and DNS-validated instead.
+		CertificateArn    string
+		CreateCertificate bool
+		// AdditionalCertificateArns are served alongside it by SNI.
+		AdditionalCertificateArns []string
+		// SslPolicy is the HTTPS listener's ELB security policy.
+		SslPolicy string
+		// HttpRedirect redirects port 80 to HTTPS; on by default. Without it
+		// CloseHttpPort removes port 80 from the load balancer's security group.
+		HttpRedirect  bool
+		CloseHttpPort bool
+
+		// AppTargetGroup tunes the health checks and draining of the app on
+		// port 8080, the target of requests no service matches.
+		AppTargetGroup components.TargetGroupSettings
+		// Services are further application ports routed to by host and path
+		// patterns; everything else goes to the app on port 8080.
+		Services []components.Service
+
+		// Scaling picks the app tier's scaling policy and schedules.
+		Scaling components.ScalingSettings
+		// AppInstanceType and the sizes configure the app autoscaling group.
+		AppInstanceType string
+		MinSize         int
+		MaxSize         int
+		DesiredCapacity int
+		// LogGroupName is the CloudWatch log group the app is told to log to.
+		LogGroupName string
+		// MixedInstances, if set, adds further instance types and spot capacity.
+		MixedInstances *components.MixedInstancesSettings
+		// InstanceRefresh rolls new launch template versions out gradually.
+		InstanceRefresh components.InstanceRefreshSettings
+		// Deployment switches the app tier between rolling and blue/green
+		// deployments.
+		Deployment components.DeploymentSettings
+
+		// Regions deploy further copies of the network, database, load
+		// balancer and app tier; the stack's own aws:region is always used.
+		Regions []RegionSettings
+		// DnsRouting is the components.Routing* policy spreading domainName
+		// over the regions' load balancers.
+		DnsRouting string
+	}
+
+	// invalid lists the keys whose values could not be parsed; validate
+	// reports them with the other problems.
+	invalid []string
+}
+
+// RegionSettings configures one further region of a multi-region stack.
+type RegionSettings struct {
+	Region string `json:"region"`
+	// CidrBlockAddr is the region's VPC CIDR; cidrBlockAddr when empty.
+	CidrBlockAddr string `json:"cidrBlockAddr"`
+	// CertificateArn and AdditionalCertificateArns are ACM certificates in
+	// the region, as certificates cannot be shared across regions. Leave
+	// them empty with createCertificate.
+	CertificateArn            string   `json:"certificateArn"`
+	AdditionalCertificateArns []string `json:"additionalCertificateArns"`
+}
+
+// getOrDefault returns the value of an optional string key, or def when unset.
+func getOrDefault(cfg *config.Config, key, def string) string {
+	if v := cfg.Get(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// intOrDefault returns the value of an optional integer key, or def when
+// unset. A value that is not an integer is recorded in c.invalid.
+func (c *Config) intOrDefault(cfg *config.Config, key string, def int) int {
+	raw := cfg.Get(key)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		c.invalid = append(c.invalid, fmt.Sprintf("%s %q is not an integer", key, raw))
+		return def
+	}
+	return v
+}
+
+// boolOrDefault returns the value of an optional boolean key, or def when
+// unset. A value that is not a boolean is recorded in c.invalid.
+func (c *Config) boolOrDefault(cfg *config.Config, key string, def bool) bool {
+	raw := cfg.Get(key)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		c.invalid = append(c.invalid, fmt.Sprintf("%s %q is not true or false", key, raw))
+		return def
+	}
+	return v
+}
+
+// getObject decodes an optional structured key into out. A value that does
+// not decode is recorded in c.invalid and leaves out at its zero value.
+func (c *Config) getObject(cfg *config.Config, key string, out interface{}) {
+	if err := cfg.GetObject(key, out); err != nil {
+		c.invalid = append(c.invalid, fmt.Sprintf("%s: %v", key, err))
+		reflect.ValueOf(out).Elem().SetZero()
+	}
+}
+
+// loadConfig populates Config from the "network" namespace of the active stack.
+// Keys used by resources that cannot be created without them are checked by
+// validate; resource names fall back to the defaults the original stacks used.
+func loadConfig(ctx *pulumi.Context) Config {
+	cfg := config.New(ctx, "network")
+
+	var c Config
+	c.Network.CIDRBlockAddr = cfg.Get("cidrBlockAddr")
+	c.Network.VPCName = getOrDefault(cfg, "vpcName", "MyVpc")
+	c.Network.InternetGateWayName = getOrDefault(cfg, "internetGatewayName", "MyInternetGateway")
+	c.Network.InternetGatewayAttachmentName = getOrDefault(cfg, "internetGatewayAttachmentName", "MyInternetGatewayAttachment")
+	c.Network.PublicRouteTableName = getOrDefault(cfg, "publicRouteTableName", "MyPublicRouteTable")
+	c.Network.PrivateRouteTableName = getOrDefault(cfg, "privateRouteTableName", "MyPrivateRouteTable")
+	c.Network.PublicRouteName = getOrDefault(cfg, "publicRouteName", "MyPublicRoute")
+	c.Network.SubNet = uint(c.intOrDefault(cfg, "subnet", 24))
+	c.Network.SSHKeyName = cfg.Get("sshKeyName")
+	c.Network.AmiName = cfg.Get("amiName")
+	c.Network.GcpBucketname = cfg.Get("gcpbucketName")
+	c.Network.MandrillKey = cfg.GetSecret("mandrillKey")
+	if password, err := cfg.TrySecret("dbPassword"); err == nil {
+		c.Network.DbPassword = password
+	}
+
+	c.Network.NatMode = getOrDefault(cfg, "natMode", components.NatNone)
+	c.Network.NatInstanceType = getOrDefault(cfg, "natInstanceType", "t3.nano")
+
+	c.Network.PrivateAppInstances = c.boolOrDefault(cfg, "privateAppInstances", false)
+
+	c.Network.DomainName = cfg.Get("domainName")
+	c.Network.HostedZoneId = cfg.Get("hostedZoneId")
+	c.Network.HostedZoneName = cfg.Get("hostedZoneName")
+	c.Network.CertificateArn = cfg.Get("certificateArn")
+	c.Network.CreateCertificate = c.boolOrDefault(cfg, "createCertificate", false)
+	c.getObject(cfg, "additionalCertificateArns", &c.Network.AdditionalCertificateArns)
+	c.Network.SslPolicy = getOrDefault(cfg, "sslPolicy", components.DefaultSslPolicy)
+	c.Network.HttpRedirect = c.boolOrDefault(cfg, "httpRedirect", true)
+	c.Network.CloseHttpPort = c.boolOrDefault(cfg, "closeHttpPort", false)
+
+	c.Network.AzCount = c.intOrDefault(cfg, "azCount", 0)
+	c.getObject(cfg, "availabilityZones", &c.Network.AvailabilityZones)
+	c.getObject(cfg, "subnetTiers", &c.Network.SubnetTiers)
+	c.getObject(cfg, "gatewayEndpoints", &c.Network.GatewayEndpoints)
+	c.getObject(cfg, "interfaceEndpoints", &c.Network.InterfaceEndpoints)
+	c.getObject(cfg, "services", &c.Network.Services)
+	c.getObject(cfg, "appTargetGroup", &c.Network.AppTargetGroup)
+	c.Network.AppTargetGroup = c.Network.AppTargetGroup.WithDefaults()
+	for i := range c.Network.Services {
+		c.Network.Services[i].TargetGroupSettings = c.Network.Services[i].TargetGroupSettings.WithDefaults()
+	}
+	c.getObject(cfg, "scaling", &c.Network.Scaling)
+	if c.Network.Scaling.Policy == "" {
+		c.Network.Scaling.Policy = components.ScalingPolicySimple
+	}
+	if c.Network.Scaling.TargetValue == 0 {
+		switch c.Network.Scaling.Policy {
+		case components.ScalingPolicyTargetCpu:
+			c.Network.Scaling.TargetValue = 50
+		case components.ScalingPolicyTargetRequests:
+			c.Network.Scaling.TargetValue = 1000
+		}
+	}
+	c.Network.AppInstanceType = getOrDefault(cfg, "appInstanceType", "t2.micro")
+	c.Network.MinSize = c.intOrDefault(cfg, "minSize", 1)
+	c.Network.MaxSize = c.intOrDefault(cfg, "maxSize", 3)
+	c.Network.DesiredCapacity = c.intOrDefault(cfg, "desiredCapacity", c.Network.MinSize)
+	c.Network.LogGroupName = getOrDefault(cfg, "logGroupName", "csye6225")
+	c.getObject(cfg, "mixedInstances", &c.Network.MixedInstances)
+	if c.Network.MixedInstances != nil && c.Network.MixedInstances.SpotAllocationStrategy == "" {
+		c.Network.MixedInstances.SpotAllocationStrategy = components.DefaultSpotAllocationStrategy
+	}
+	c.getObject(cfg, "instanceRefresh", &c.Network.InstanceRefresh)
+	c.Network.InstanceRefresh = c.Network.InstanceRefresh.WithDefaults()
+	c.getObject(cfg, "ami", &c.Network.Ami)
+	if c.Network.Ami.Name == "" {
+		c.Network.Ami.Name = c.Network.AmiName
+	}
+	c.Network.Ami = c.Network.Ami.WithDefaults()
+	c.getObject(cfg, "deployment", &c.Network.Deployment)
+	c.Network.Deployment = c.Network.Deployment.WithDefaults()
+	c.getObject(cfg, "regions", &c.Network.Regions)
+	for i := range c.Network.Regions {
+		if c.Network.Regions[i].CidrBlockAddr == "" {
+			c.Network.Regions[i].CidrBlockAddr = c.Network.CIDRBlockAddr
+		}
+	}
+	c.Network.DnsRouting = getOrDefault(cfg, "dnsRouting", components.RoutingLatency)
+	if len(c.Network.SubnetTiers) == 0 {
+		c.Network.SubnetTiers = []components.SubnetTier{
+			{Name: "public", Kind: components.TierPublic, PrefixLength: c.Network.SubNet},
+			{Name: "private", Kind: components.TierPrivate, PrefixLength: c.Network.SubNet},
+		}
+	}
+	return c
+}
+
+// zones picks the availability zones to deploy into from those available.
+func (c Config) zones(available []string) []string {
+	if len(c.Network.AvailabilityZones) > 0 {
+		return c.Network.AvailabilityZones
+	}
+	if c.Network.AzCount > 0 {
+		return available[:min(c.Network.AzCount, len(available))]
+	}
+	return available[:min(defaultAzCount, len(available))]
+}
+
+// firstTier returns the first subnet tier of the given kind, if there is one.
+func (c Config) firstTier(kind string) (components.SubnetTier, bool) {
+	for _, tier := range c.Network.SubnetTiers {
+		if tier.Kind == kind {
+			return tier, true
+		}
+	}
+	return components.SubnetTier{}, false
+}
+
+// appPorts lists the ports the load balancer reaches the app instances on:
+// the app and SSH, then each service's port once.
+func (c Config) appPorts() []int {
+	ports := []int{8080, 22}
+	for _, service := range c.Network.Services {
+		if !slices.Contains(ports, service.Port) {
+			ports = append(ports, service.Port)
+		}
+	}
+	return ports
+}
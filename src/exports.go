@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"pulumi-infra-setup/components"
+)
+
+// stackExports lists the stack outputs other stacks and the webapp CI read
+// through a StackReference. Renaming a key breaks those consumers.
+func stackExports(network *components.Network, ami components.Ami, database *components.Database,
+	notifications *components.Notifications, gcpStorage *components.GcpStorageAccess,
+	loadBalancer *components.LoadBalancer, appTier *components.AppTier) pulumi.Map {
+	exports := pulumi.Map{
+		"vpcId":             network.VpcId,
+		"vpcCidrBlock":      network.VpcCidrBlock,
+		"publicSubnetIds":   components.StringIDs(network.PublicSubnetIDs),
+		"privateSubnetIds":  components.StringIDs(network.PrivateSubnetIDs),
+		"isolatedSubnetIds": components.StringIDs(network.IsolatedSubnetIDs),
+
+		"albArn":                 loadBalancer.Arn,
+		"albDnsName":             loadBalancer.DnsName,
+		"albZoneId":              loadBalancer.ZoneId,
+		"url":                    pulumi.Sprintf("https://%s", loadBalancer.DomainName),
+		"targetGroupArn":         loadBalancer.TargetGroupArn,
+		"serviceTargetGroupArns": pulumi.ToStringMapOutput(loadBalancer.ServiceTargetGroupArns),
+		"autoScalingGroupName":   appTier.AutoScalingGroupName,
+		"amiId":                  pulumi.String(ami.Id),
+		"amiCreationDate":        pulumi.String(ami.CreationDate),
+		// Empty outside blue/green deployments
+		"colorTargetGroupArns":   pulumi.ToStringMapOutput(loadBalancer.ColorTargetGroupArns),
+		"colorAutoScalingGroups": pulumi.ToStringMapOutput(appTier.AutoScalingGroupNames),
+
+		"dbEndpoint":          database.Endpoint,
+		"dbAddress":           database.Address,
+		"dbPort":              database.Port,
+		"dbName":              database.DbName,
+		"dbUsername":          database.Username,
+		"dbPasswordSecretArn": database.PasswordSecretArn,
+
+		"snsTopicArn":       notifications.TopicArn,
+		"dynamoDbTableName": notifications.TableName,
+	}
+	// The Lambda and GCP service account only exist with a GCP bucket
+	if gcpStorage != nil {
+		exports["lambdaFunctionArn"] = notifications.FunctionArn
+		exports["gcpServiceAccountEmail"] = gcpStorage.Email
+		exports["gcpServiceAccountKey"] = gcpStorage.PrivateKey
+	}
+	return exports
+}
+
+// regionExports lists the outputs of one region's tiers, exported by region
+// name under "regions".
+func regionExports(tiers *regionalTiers) pulumi.Map {
+	return pulumi.Map{
+		"vpcId":                tiers.network.VpcId,
+		"albArn":               tiers.loadBalancer.Arn,
+		"albDnsName":           tiers.loadBalancer.DnsName,
+		"albZoneId":            tiers.loadBalancer.ZoneId,
+		"autoScalingGroupName": tiers.appTier.AutoScalingGroupName,
+		"amiId":                tiers.amiId,
+		"dbEndpoint":           tiers.database.Endpoint,
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/ec2"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"pulumi-infra-setup/components"
+)
+
+// region is one AWS region the regional tiers are deployed into.
+type region struct {
+	Name string
+	// NamePrefix tells this region's resources apart from the home
+	// region's, which have none.
+	NamePrefix string
+	// ElbNamePrefix starts the AWS names of the load balancer and target
+	// groups; empty for the home region, whose names derive from the
+	// resource names.
+	ElbNamePrefix string
+	// Provider targets the region; nil for the stack's own region.
+	Provider pulumi.ProviderResource
+
+	CidrBlock                 string
+	Zones                     []string
+	CertificateArn            string
+	AdditionalCertificateArns []string
+	// Routing places the region's load balancer in the DNS record set;
+	// nil for a single-region stack.
+	Routing *components.RecordRouting
+	// ValidationRecordFqdn is the home region's certificate validation
+	// record, which the region's certificate shares; nil for the home
+	// region, which writes it.
+	ValidationRecordFqdn pulumi.StringInput
+
+	// AmiId returns the region's copy of an AMI of the home region.
+	AmiId func(amiId string) (pulumi.StringInput, error)
+}
+
+// directionCodes abbreviate the directional words of region names.
+var directionCodes = map[string]string{
+	"north": "n", "south": "s", "east": "e", "west": "w", "central": "c",
+	"northeast": "ne", "northwest": "nw", "southeast": "se", "southwest": "sw",
+}
+
+// regionCode abbreviates a region name, e.g. us-west-2 to usw2 and
+// ap-southeast-1 to apse1.
+func regionCode(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "-") {
+		switch {
+		case directionCodes[part] != "":
+			b.WriteString(directionCodes[part])
+		case len(part) > 2 && !strings.ContainsAny(part[:1], "0123456789"):
+			b.WriteString(part[:1])
+		default:
+			b.WriteString(part)
+		}
+	}
+	return b.String()
+}
+
+// elbNamePrefix is the prefix AWS names a further region's load balancer
+// and target groups with. Their names would otherwise derive from the
+// prefixed resource names and exceed the 32 characters ELB allows.
+func elbNamePrefix(region string) string {
+	return regionCode(region) + "-"
+}
+
+// maxElbNamePrefix is the longest name prefix ELB accepts.
+const maxElbNamePrefix = 6
+
+// regionalTiers are the components created in every region.
+type regionalTiers struct {
+	network *components.Network
+	// certificate is nil unless createCertificate is set.
+	certificate  *components.Certificate
+	database     *components.Database
+	loadBalancer *components.LoadBalancer
+	appTier      *components.AppTier
+	// amiId is the image the app tier runs in the region.
+	amiId pulumi.StringInput
+}
+
+// newRegion sets up a provider for a further region and looks up its
+// availability zones, using as many as the home region. AMIs are copied
+// into the region from home when first asked for.
+func newRegion(ctx *pulumi.Context, settings RegionSettings, home string, zoneCount int) (region, error) {
+	r := region{
+		Name:                      settings.Region,
+		NamePrefix:                settings.Region + "-",
+		ElbNamePrefix:             elbNamePrefix(settings.Region),
+		CidrBlock:                 settings.CidrBlockAddr,
+		CertificateArn:            settings.CertificateArn,
+		AdditionalCertificateArns: settings.AdditionalCertificateArns,
+	}
+	provider, err := aws.NewProvider(ctx, "provider-"+settings.Region, &aws.ProviderArgs{
+		Region: pulumi.String(settings.Region),
+	})
+	if err != nil {
+		return r, err
+	}
+	r.Provider = provider
+
+	available, err := aws.GetAvailabilityZones(ctx, &aws.GetAvailabilityZonesArgs{
+		State: pulumi.StringRef("available"),
+	}, pulumi.Provider(provider))
+	if err != nil {
+		return r, fmt.Errorf("listing availability zones of %s: %w", settings.Region, err)
+	}
+	r.Zones = available.Names[:min(zoneCount, len(available.Names))]
+	ctx.Log.Info(fmt.Sprintf("Deploying VPC %s across %s", r.CidrBlock, strings.Join(r.Zones, ", ")), nil)
+
+	copies := map[string]pulumi.StringInput{}
+	r.AmiId = func(amiId string) (pulumi.StringInput, error) {
+		if copied, ok := copies[amiId]; ok {
+			return copied, nil
+		}
+		amiCopy, err := ec2.NewAmiCopy(ctx, r.NamePrefix+"amiCopy-"+amiId, &ec2.AmiCopyArgs{
+			Name:            pulumi.Sprintf("%s-from-%s", amiId, home),
+			SourceAmiId:     pulumi.String(amiId),
+			SourceAmiRegion: pulumi.String(home),
+		}, pulumi.Provider(provider))
+		if err != nil {
+			return nil, err
+		}
+		copies[amiId] = amiCopy.ID().ToStringOutput()
+		return copies[amiId], nil
+	}
+	return r, nil
+}
+
+// createRegion creates the network, database, load balancer and app tier in
+// r. topicArn is the notification topic in topicRegion, the home region.
+func createRegion(ctx *pulumi.Context, config Config, r region, ami components.Ami, zoneId string,
+	topicArn pulumi.StringOutput, topicRegion string) (*regionalTiers, error) {
+	var opts []pulumi.ResourceOption
+	if r.Provider != nil {
+		opts = append(opts, pulumi.Provider(r.Provider))
+	}
+	name := func(component string) string {
+		return r.NamePrefix + component
+	}
+
+	network, err := components.NewNetwork(ctx, name("network"), &components.NetworkArgs{
+		NamePrefix:                    r.NamePrefix,
+		CidrBlock:                     r.CidrBlock,
+		AvailabilityZones:             r.Zones,
+		Tiers:                         config.Network.SubnetTiers,
+		VpcName:                       config.Network.VPCName,
+		InternetGatewayName:           config.Network.InternetGateWayName,
+		InternetGatewayAttachmentName: config.Network.InternetGatewayAttachmentName,
+		PublicRouteTableName:          config.Network.PublicRouteTableName,
+		PrivateRouteTableName:         config.Network.PrivateRouteTableName,
+		PublicRouteName:               config.Network.PublicRouteName,
+		NatMode:                       config.Network.NatMode,
+		NatInstanceType:               config.Network.NatInstanceType,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	loadBalancerPorts := []int{80, 443}
+	if config.Network.CloseHttpPort {
+		loadBalancerPorts = []int{443}
+	}
+	securityGroups, err := components.NewSecurityGroups(ctx, name("securityGroups"), &components.SecurityGroupsArgs{
+		NamePrefix:        r.NamePrefix,
+		VpcId:             network.VpcId,
+		LoadBalancerPorts: loadBalancerPorts,
+		AppPorts:          config.appPorts(),
+		DatabasePort:      3306,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The load balancer and app instances use the first public tier's
+	// subnets, one per zone, however many zones there are
+	publicTier, _ := config.firstTier(components.TierPublic)
+	publicSubnetIDs := network.TierSubnetIDs[publicTier.Name]
+
+	// App instances stay in the public tier unless they are kept private
+	appSubnetIDs := publicSubnetIDs
+	if config.Network.PrivateAppInstances {
+		privateTier, _ := config.firstTier(components.TierPrivate)
+		appSubnetIDs = network.TierSubnetIDs[privateTier.Name]
+	}
+
+	if len(config.Network.GatewayEndpoints) > 0 || len(config.Network.InterfaceEndpoints) > 0 {
+		privateTier, _ := config.firstTier(components.TierPrivate)
+		_, err = components.NewVpcEndpoints(ctx, name("vpcEndpoints"), &components.VpcEndpointsArgs{
+			NamePrefix:        r.NamePrefix,
+			VpcId:             network.VpcId,
+			VpcCidrBlock:      network.VpcCidrBlock,
+			GatewayServices:   config.Network.GatewayEndpoints,
+			RouteTableIDs:     network.RouteTableIDs,
+			InterfaceServices: config.Network.InterfaceEndpoints,
+			SubnetIDs:         network.TierSubnetIDs[privateTier.Name],
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The database goes in the isolated tiers when there are any
+	dbSubnetIDs := network.PrivateSubnetIDs
+	if len(network.IsolatedSubnetIDs) > 0 {
+		dbSubnetIDs = network.IsolatedSubnetIDs
+	}
+	database, err := components.NewDatabase(ctx, name("database"), &components.DatabaseArgs{
+		NamePrefix:      r.NamePrefix,
+		SubnetIDs:       dbSubnetIDs,
+		SecurityGroupId: securityGroups.DatabaseId,
+		Identifier:      "csye6225",
+		DbName:          "csye6225",
+		Username:        "csye6225",
+		Password:        config.Network.DbPassword,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Certificates are regional, so each region requests its own
+	certificateArn := pulumi.String(r.CertificateArn).ToStringOutput()
+	var certificate *components.Certificate
+	if config.Network.CreateCertificate {
+		certificate, err = components.NewCertificate(ctx, name("certificate"), &components.CertificateArgs{
+			NamePrefix:           r.NamePrefix,
+			DomainName:           config.Network.DomainName,
+			ZoneId:               zoneId,
+			ValidationRecordFqdn: r.ValidationRecordFqdn,
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+		certificateArn = certificate.Arn
+	}
+
+	// Blue/green deployments weight the listener between the two colors
+	var weights map[string]int
+	if config.Network.Deployment.BlueGreen() {
+		weights = config.Network.Deployment.Weights()
+	}
+	loadBalancer, err := components.NewLoadBalancer(ctx, name("loadBalancer"), &components.LoadBalancerArgs{
+		NamePrefix:                r.NamePrefix,
+		ElbNamePrefix:             r.ElbNamePrefix,
+		VpcId:                     network.VpcId,
+		SubnetIDs:                 publicSubnetIDs,
+		SecurityGroupId:           securityGroups.LoadBalancerId,
+		TargetPort:                8080,
+		TargetGroup:               config.Network.AppTargetGroup,
+		Weights:                   weights,
+		Services:                  config.Network.Services,
+		CertificateArn:            certificateArn,
+		AdditionalCertificateArns: r.AdditionalCertificateArns,
+		SslPolicy:                 config.Network.SslPolicy,
+		HttpRedirect:              config.Network.HttpRedirect,
+		DomainName:                config.Network.DomainName,
+		ZoneId:                    zoneId,
+		RecordRouting:             r.Routing,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// amiId is the image of the rolling group or the active color. Only the
+	// images a group runs are resolved, so a further region copies no AMI
+	// that both pinned colors leave unused.
+	var amiId pulumi.StringInput
+	var colors []components.ColorGroup
+	if config.Network.Deployment.BlueGreen() {
+		for _, color := range components.Colors {
+			colorAmiId, err := r.AmiId(config.Network.Deployment.AmiId(color, ami.Id))
+			if err != nil {
+				return nil, err
+			}
+			active := color == config.Network.Deployment.ActiveColor
+			if active {
+				amiId = colorAmiId
+			}
+			colors = append(colors, components.ColorGroup{
+				Color:          color,
+				AmiId:          colorAmiId,
+				TargetGroupArn: loadBalancer.ColorTargetGroupArns[color],
+				RequestCountResourceLabel: pulumi.Sprintf("%s/%s",
+					loadBalancer.ArnSuffix, loadBalancer.ColorTargetGroupArnSuffixes[color]),
+				Active: active,
+			})
+		}
+	} else {
+		amiId, err = r.AmiId(ami.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	appTier, err := components.NewAppTier(ctx, name("appTier"), &components.AppTierArgs{
+		NamePrefix:             r.NamePrefix,
+		AmiId:                  amiId,
+		InstanceType:           config.Network.AppInstanceType,
+		MinSize:                config.Network.MinSize,
+		MaxSize:                config.Network.MaxSize,
+		DesiredCapacity:        config.Network.DesiredCapacity,
+		MixedInstances:         config.Network.MixedInstances,
+		InstanceRefresh:        config.Network.InstanceRefresh,
+		SSHKeyName:             config.Network.SSHKeyName,
+		SubnetIDs:              appSubnetIDs,
+		PrivateInstances:       config.Network.PrivateAppInstances,
+		SecurityGroupId:        securityGroups.AppId,
+		TargetGroupArn:         loadBalancer.TargetGroupArn,
+		ServiceTargetGroupArns: loadBalancer.ServiceTargetGroupArns,
+		Colors:                 colors,
+		Scaling:                config.Network.Scaling,
+		RequestCountResourceLabel: pulumi.Sprintf("%s/%s",
+			loadBalancer.ArnSuffix, loadBalancer.TargetGroupArnSuffix),
+		DbAddress:    database.Address,
+		DbPort:       database.Port,
+		DbName:       database.DbName,
+		DbUsername:   database.Username,
+		DbSecretArn:  database.PasswordSecretArn,
+		TopicArn:     topicArn,
+		TopicRegion:  topicRegion,
+		Region:       r.Name,
+		LogGroupName: config.Network.LogGroupName,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &regionalTiers{
+		network:      network,
+		certificate:  certificate,
+		database:     database,
+		loadBalancer: loadBalancer,
+		appTier:      appTier,
+		amiId:        amiId,
+	}, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws"
+	"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/route53"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+	"pulumi-infra-setup/components"
+)
+
+// createStack registers every resource of the stack. It is kept separate from
+// main so tests can run it against Pulumi mocks.
+func createStack(ctx *pulumi.Context) error {
+	config := loadConfig(ctx)
+
+	// Get number of availability zones
+	available, err := aws.GetAvailabilityZones(ctx, &aws.GetAvailabilityZonesArgs{
+		State: pulumi.StringRef("available"),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	home, err := aws.GetRegion(ctx, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	// Validate the stack config before registering any resource
+	if err := config.validate(available.Names, home.Name); err != nil {
+		return err
+	}
+
+	// Without a second instance, a refresh takes the only one out of service
+	if *config.Network.InstanceRefresh.Enabled && config.Network.MinSize < 2 {
+		ctx.Log.Warn(fmt.Sprintf("instanceRefresh with minSize %d leaves the app down while an instance is replaced; "+
+			"set minSize to 2 or more to roll new instances without downtime", config.Network.MinSize), nil)
+	}
+
+	zones := config.zones(available.Names)
+	ctx.Log.Debug(fmt.Sprintf("%d availability zones available: %s", len(available.Names), strings.Join(available.Names, ", ")), nil)
+	ctx.Log.Info(fmt.Sprintf("Deploying VPC %s across %s", config.Network.CIDRBlockAddr, strings.Join(zones, ", ")), nil)
+
+	// Find the AMI the app instances run
+	ami, err := components.ResolveAmi(ctx, config.Network.Ami)
+	if err != nil {
+		return err
+	}
+	ctx.Log.Info(fmt.Sprintf("Using AMI %s (%s, created %s)", ami.Id, ami.Name, ami.CreationDate), nil)
+
+	// The submission Lambda and its GCP service account need a bucket
+	var gcpStorage *components.GcpStorageAccess
+	var gcpKey pulumi.StringOutput
+	if config.Network.GcpBucketname != "" {
+		gcpStorage, err = components.NewGcpStorageAccess(ctx, "gcpStorageAccess", &components.GcpStorageAccessArgs{
+			BucketName:  config.Network.GcpBucketname,
+			AccountId:   "service-account-id",
+			DisplayName: "Service Account",
+		})
+		if err != nil {
+			return err
+		}
+		gcpKey = gcpStorage.PrivateKey
+	}
+
+	notifications, err := components.NewNotifications(ctx, "notifications", &components.NotificationsArgs{
+		LambdaCodePath: "./myFunction.zip",
+		GcpKey:         gcpKey,
+		GcpBucketName:  config.Network.GcpBucketname,
+		MandrillKey:    config.Network.MandrillKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Find the hosted zone the load balancers are aliased in
+	zoneId := config.Network.HostedZoneId
+	if zoneId == "" {
+		zone, err := route53.LookupZone(ctx, &route53.LookupZoneArgs{
+			Name:        pulumi.StringRef(config.Network.HostedZoneName),
+			PrivateZone: pulumi.BoolRef(false),
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("looking up hosted zone %q: %w", config.Network.HostedZoneName, err)
+		}
+		zoneId = zone.ZoneId
+	}
+
+	// The stack's own region keeps the resource names it always had
+	homeRegion := region{
+		Name:                      home.Name,
+		CidrBlock:                 config.Network.CIDRBlockAddr,
+		Zones:                     zones,
+		CertificateArn:            config.Network.CertificateArn,
+		AdditionalCertificateArns: config.Network.AdditionalCertificateArns,
+		AmiId: func(amiId string) (pulumi.StringInput, error) {
+			return pulumi.String(amiId), nil
+		},
+	}
+	if len(config.Network.Regions) > 0 {
+		homeRegion.Routing = &components.RecordRouting{
+			Policy:  config.Network.DnsRouting,
+			Region:  home.Name,
+			Primary: true,
+		}
+	}
+	tiers, err := createRegion(ctx, config, homeRegion, ami, zoneId, notifications.TopicArn, home.Name)
+	if err != nil {
+		return err
+	}
+
+	regions := pulumi.Map{home.Name: regionExports(tiers)}
+	for _, settings := range config.Network.Regions {
+		r, err := newRegion(ctx, settings, home.Name, len(zones))
+		if err != nil {
+			return err
+		}
+		r.Routing = &components.RecordRouting{
+			Policy: config.Network.DnsRouting,
+			Region: settings.Region,
+		}
+		// The home region owns the certificate validation record, so
+		// removing another region never deletes it
+		if tiers.certificate != nil {
+			r.ValidationRecordFqdn = tiers.certificate.ValidationRecordFqdn
+		}
+		regionTiers, err := createRegion(ctx, config, r, ami, zoneId, notifications.TopicArn, home.Name)
+		if err != nil {
+			return err
+		}
+		regions[settings.Region] = regionExports(regionTiers)
+	}
+
+	exports := stackExports(tiers.network, ami, tiers.database, notifications, gcpStorage, tiers.loadBalancer, tiers.appTier)
+	exports["regions"] = regions
+	for name, value := range exports {
+		ctx.Export(name, value)
+	}
+	return nil
+}
@@ -0,0 +1,1842 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v3"
+)
+
+// mockedResource is a resource registered with the mocks, keyed by its name.
+type mockedResource struct {
+	Type   string
+	Inputs resource.PropertyMap
+	// IgnoreChanges are the input properties updates leave alone.
+	IgnoreChanges []string
+}
+
+// mocks answers provider calls offline and records every registered resource.
+type mocks struct {
+	azs []string
+	// preview leaves ARNs and database addresses unknown, as in a first preview.
+	preview bool
+
+	// amiCreationDate is the creation date of every image getAmi finds.
+	amiCreationDate string
+
+	mu        sync.Mutex
+	resources map[string]mockedResource
+	// calls holds the arguments of the last call to each function token.
+	calls map[string]resource.PropertyMap
+}
+
+func newMocks(azs ...string) *mocks {
+	return &mocks{
+		azs:             azs,
+		amiCreationDate: time.Now().UTC().Add(-48 * time.Hour).Format(time.RFC3339),
+		resources:       map[string]mockedResource{},
+		calls:           map[string]resource.PropertyMap{},
+	}
+}
+
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mu.Lock()
+	m.resources[args.Name] = mockedResource{
+		Type:          args.TypeToken,
+		Inputs:        args.Inputs,
+		IgnoreChanges: args.RegisterRPC.GetIgnoreChanges(),
+	}
+	m.mu.Unlock()
+
+	outputs := args.Inputs.Copy()
+	switch args.TypeToken {
+	case "aws:rds/instance:Instance":
+		outputs["endpoint"] = resource.NewStringProperty("db.example.internal:3306")
+		outputs["address"] = resource.NewStringProperty("db.example.internal")
+		outputs["port"] = resource.NewNumberProperty(3306)
+	case "aws:sns/topic:Topic":
+		outputs["arn"] = resource.NewStringProperty("arn:aws:sns:us-east-1:123456789012:" + args.Name)
+	case "aws:dynamodb/table:Table":
+		outputs["name"] = resource.NewStringProperty(args.Name + "-table")
+	case "aws:ec2/instance:Instance":
+		outputs["primaryNetworkInterfaceId"] = resource.NewStringProperty(args.Name + "-eni")
+	case "aws:acm/certificate:Certificate":
+		outputs["domainValidationOptions"] = resource.NewArrayProperty([]resource.PropertyValue{
+			resource.NewObjectProperty(resource.NewPropertyMapFromMap(map[string]interface{}{
+				"domainName":          args.Inputs["domainName"].StringValue(),
+				"resourceRecordName":  "_token." + args.Inputs["domainName"].StringValue() + ".",
+				"resourceRecordType":  "CNAME",
+				"resourceRecordValue": "_token.acm-validations.aws.",
+			})),
+		})
+	case "aws:lb/loadBalancer:LoadBalancer":
+		outputs["arnSuffix"] = resource.NewStringProperty("app/" + args.Name + "/1234")
+	case "aws:lb/targetGroup:TargetGroup":
+		outputs["arnSuffix"] = resource.NewStringProperty("targetgroup/" + args.Name + "/5678")
+	case "aws:route53/record:Record":
+		outputs["fqdn"] = args.Inputs["name"]
+	case "aws:ec2/launchTemplate:LaunchTemplate":
+		outputs["latestVersion"] = resource.NewNumberProperty(3)
+	case "gcp:serviceaccount/key:Key":
+		outputs["privateKey"] = resource.NewStringProperty("private-key")
+	case "gcp:serviceaccount/account:Account":
+		outputs["email"] = resource.NewStringProperty("sa@example.iam.gserviceaccount.com")
+		outputs["member"] = resource.NewStringProperty("serviceAccount:sa@example.iam.gserviceaccount.com")
+	}
+	if _, ok := outputs["arn"]; !ok {
+		outputs["arn"] = resource.NewStringProperty("arn:mock:" + args.Name)
+	}
+	if _, ok := outputs["name"]; !ok {
+		outputs["name"] = resource.NewStringProperty(args.Name)
+	}
+	if m.preview {
+		for _, key := range []resource.PropertyKey{"arn", "address", "endpoint"} {
+			if _, ok := outputs[key]; ok {
+				outputs[key] = resource.MakeComputed(resource.NewStringProperty(""))
+			}
+		}
+	}
+	return args.Name + "_id", outputs, nil
+}
+
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	m.mu.Lock()
+	m.calls[args.Token] = args.Args
+	m.mu.Unlock()
+
+	switch args.Token {
+	case "aws:index/getAvailabilityZones:getAvailabilityZones":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{
+			"names": m.azs,
+		}), nil
+	case "aws:ssm/getParameter:getParameter":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{
+			"value": "ami-0fedcba9876543210",
+		}), nil
+	case "aws:index/getRegion:getRegion":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{
+			"name": "us-east-1",
+		}), nil
+	case "aws:route53/getZone:getZone":
+		return resource.NewPropertyMapFromMap(map[string]interface{}{
+			"name":   args.Args["name"].StringValue(),
+			"zoneId": "ZLOOKEDUP",
+		}), nil
+	case "aws:ec2/getAmi:getAmi":
+		// Lookups by ID find that image, any other the latest webapp image
+		id := "ami-0123456789abcdef0"
+		for _, filter := range args.Args["filters"].ArrayValue() {
+			if filter.ObjectValue()["name"].StringValue() == "image-id" {
+				id = filter.ObjectValue()["values"].ArrayValue()[0].StringValue()
+			}
+		}
+		return resource.NewPropertyMapFromMap(map[string]interface{}{
+			"id":           id,
+			"name":         "webapp-ami",
+			"creationDate": m.amiCreationDate,
+		}), nil
+	}
+	return args.Args, nil
+}
+
+// ofType returns the names of the recorded resources with the given type token.
+func (m *mocks) ofType(typ string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name, r := range m.resources {
+		if r.Type == typ {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inputs returns the inputs of the named resource, failing the test if absent.
+func (m *mocks) inputs(t *testing.T, name string) resource.PropertyMap {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.resources[name]
+	if !ok {
+		t.Fatalf("resource %q was not registered", name)
+	}
+	return r.Inputs
+}
+
+// ignoredChanges returns the sorted ignoreChanges of the named resource.
+func (m *mocks) ignoredChanges(t *testing.T, name string) []string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.resources[name]
+	if !ok {
+		t.Fatalf("resource %q was not registered", name)
+	}
+	ignored := slices.Clone(r.IgnoreChanges)
+	sort.Strings(ignored)
+	return ignored
+}
+
+// testConfig is a minimal valid network configuration.
+func testConfig() map[string]string {
+	return map[string]string{
+		"network:cidrBlockAddr":  "10.2.0.0/16",
+		"network:subnet":         "24",
+		"network:sshKeyName":     "testkey",
+		"network:amiName":        "webapp-ami",
+		"network:gcpbucketName":  "test-bucket",
+		"network:mandrillKey":    "mandrill",
+		"network:domainName":     "app.example.com",
+		"network:hostedZoneId":   "ZCONFIGURED",
+		"network:certificateArn": "arn:aws:acm:us-east-1:123456789012:certificate/existing",
+	}
+}
+
+// runStack runs createStack against mocks with the given config.
+func runStack(m *mocks, cfg map[string]string) error {
+	return pulumi.RunErr(createStack,
+		pulumi.WithMocks("pulumi-infra-setup", "test", m),
+		func(info *pulumi.RunInfo) { info.Config = cfg },
+	)
+}
+
+// stackFileConfig reads the config of a committed Pulumi.<stack>.yaml.
+// Secrets cannot be decrypted here, so they are replaced by a placeholder.
+func stackFileConfig(t *testing.T, path string) map[string]string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Config map[string]interface{} `yaml:"config"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	cfg := map[string]string{}
+	for key, value := range file.Config {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if _, ok := v["secure"]; !ok {
+				t.Fatalf("%s: %s is neither a scalar nor a secret", path, key)
+			}
+			cfg[key] = "secret"
+		default:
+			cfg[key] = fmt.Sprint(v)
+		}
+	}
+	return cfg
+}
+
+func TestStackFiles(t *testing.T) {
+	paths, err := filepath.Glob("Pulumi.*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("found no stack files")
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			m := newMocks("us-east-1a", "us-east-1b", "us-east-1c")
+			if err := runStack(m, stackFileConfig(t, path)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestStackWithoutGcpBucket(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	delete(cfg, "network:gcpbucketName")
+	delete(cfg, "network:mandrillKey")
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, typ := range []string{"aws:lambda/function:Function", "gcp:serviceaccount/account:Account", "aws:sns/topicSubscription:TopicSubscription"} {
+		if got := m.ofType(typ); len(got) != 0 {
+			t.Errorf("registered %s %v without a GCP bucket", typ, got)
+		}
+	}
+	if got := m.ofType("aws:sns/topic:Topic"); len(got) != 1 {
+		t.Errorf("topics = %v, want the one the app publishes to", got)
+	}
+}
+
+func TestStackResourceCounts(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{
+		"aws:ec2/vpc:Vpc":       1,
+		"aws:ec2/subnet:Subnet": 6,
+		"aws:ec2/routeTableAssociation:RouteTableAssociation": 6,
+		"aws:ec2/securityGroup:SecurityGroup":                 3,
+		"aws:rds/instance:Instance":                           1,
+		"aws:lb/loadBalancer:LoadBalancer":                    1,
+		"aws:lb/listener:Listener":                            2,
+		"aws:autoscaling/group:Group":                         1,
+		"aws:lambda/function:Function":                        1,
+		"aws:sns/topicSubscription:TopicSubscription":         1,
+	}
+	for typ, want := range counts {
+		if got := len(m.ofType(typ)); got != want {
+			t.Errorf("%s: got %d resources, want %d", typ, got, want)
+		}
+	}
+}
+
+func TestStackPreviewWithUnknownOutputs(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	m.preview = true
+	err := pulumi.RunErr(createStack,
+		pulumi.WithMocks("pulumi-infra-setup", "test", m),
+		func(info *pulumi.RunInfo) {
+			info.Config = testConfig()
+			info.DryRun = true
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Resources depending on unknown values must still be part of the plan
+	for _, name := range []string{
+		"myLambdaFunction", "myLambdaPermission", "mySubscription",
+		"launchTemplate", "asg", "dbSecretReadPolicy", "targpattachment",
+	} {
+		m.inputs(t, name)
+	}
+}
+
+func TestStackSubnetCIDRs(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"publicSubnet-us-east-1a":  "10.2.1.0/24",
+		"privateSubnet-us-east-1a": "10.2.2.0/24",
+		"publicSubnet-us-east-1b":  "10.2.3.0/24",
+		"privateSubnet-us-east-1b": "10.2.4.0/24",
+	}
+	if got := len(m.ofType("aws:ec2/subnet:Subnet")); got != len(want) {
+		t.Errorf("got %d subnets, want %d", got, len(want))
+	}
+	for name, cidr := range want {
+		if got := m.inputs(t, name)["cidrBlock"].StringValue(); got != cidr {
+			t.Errorf("%s: cidrBlock = %s, want %s", name, got, cidr)
+		}
+	}
+}
+
+func TestStackSubnetTiersAndAzCount(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d")
+	cfg := testConfig()
+	cfg["network:azCount"] = "2"
+	cfg["network:subnetTiers"] = `[
+		{"name": "public", "kind": "public", "prefixLength": 24},
+		{"name": "private-app", "kind": "private", "prefixLength": 22},
+		{"name": "private-db", "kind": "isolated", "prefixLength": 26}
+	]`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(m.ofType("aws:ec2/subnet:Subnet")); got != 6 {
+		t.Errorf("got %d subnets, want 6", got)
+	}
+	if got := m.inputs(t, "private-appSubnet-us-east-1b")["cidrBlock"].StringValue(); got != "10.2.12.0/22" {
+		t.Errorf("private-app subnet in us-east-1b = %s, want 10.2.12.0/22", got)
+	}
+	if got := m.inputs(t, "private-dbSubnet2-RouteTableAssociation")["routeTableId"].StringValue(); got != "isolatedRouteTable_id" {
+		t.Errorf("private-db subnets are routed through %s, want isolatedRouteTable_id", got)
+	}
+
+	var dbSubnets []string
+	for _, id := range m.inputs(t, "dbsubnetgroup")["subnetIds"].ArrayValue() {
+		dbSubnets = append(dbSubnets, id.StringValue())
+	}
+	want := "[private-dbSubnet-us-east-1a_id private-dbSubnet-us-east-1b_id]"
+	if fmt.Sprint(dbSubnets) != want {
+		t.Errorf("database subnets = %v, want %s", dbSubnets, want)
+	}
+}
+
+func TestStackTwoZoneRegion(t *testing.T) {
+	m := newMocks("eu-south-2a", "eu-south-2b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[publicSubnet-eu-south-2a_id publicSubnet-eu-south-2b_id]"
+	for name, key := range map[string]resource.PropertyKey{
+		"asg":              "vpcZoneIdentifiers",
+		"testloadBalancer": "subnets",
+	} {
+		var subnets []string
+		for _, id := range m.inputs(t, name)[key].ArrayValue() {
+			subnets = append(subnets, id.StringValue())
+		}
+		if fmt.Sprint(subnets) != want {
+			t.Errorf("%s %s = %v, want %s", name, key, subnets, want)
+		}
+	}
+}
+
+func TestStackRejectsSingleZone(t *testing.T) {
+	m := newMocks("us-east-1a")
+	err := runStack(m, testConfig())
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if !strings.Contains(configErr.Error(), "at least 2 availability zones") {
+		t.Errorf("error does not mention the load balancer zone minimum: %v", configErr)
+	}
+	if got := len(m.ofType("aws:autoscaling/group:Group")); got != 0 {
+		t.Errorf("registered %d autoscaling groups despite invalid config", got)
+	}
+}
+
+func TestStackRejectsUnparseableValues(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:minSize"] = "one"
+	cfg["network:azCount"] = "two"
+	cfg["network:httpRedirect"] = "sometimes"
+	err := runStack(m, cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	for _, want := range []string{
+		`minSize "one" is not an integer`,
+		`azCount "two" is not an integer`,
+		`httpRedirect "sometimes" is not true or false`,
+	} {
+		if !slices.Contains(configErr.Problems, want) {
+			t.Errorf("problems %q do not include %q", configErr.Problems, want)
+		}
+	}
+}
+
+func TestStackRejectsMissingCidrBlock(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	delete(cfg, "network:cidrBlockAddr")
+	// The region inherits the missing CIDR, which is reported once
+	cfg["network:regions"] = `[{"region": "us-west-2",
+		"certificateArn": "arn:aws:acm:us-west-2:123456789012:certificate/west"}]`
+	err := runStack(m, cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if want := []string{"cidrBlockAddr must be set"}; !slices.Equal(configErr.Problems, want) {
+		t.Errorf("problems = %q, want %q", configErr.Problems, want)
+	}
+}
+
+func TestStackReportsEveryUndecodableObject(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:services"] = `{"name": "api"}`
+	cfg["network:scaling"] = `{"policy": 3}`
+	cfg["network:minSize"] = "one"
+	err := runStack(m, cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	// The objects that fail to decode are left unset, adding no problems
+	if len(configErr.Problems) != 3 {
+		t.Errorf("got %d problems, want 3: %v", len(configErr.Problems), configErr.Problems)
+	}
+	for _, want := range []string{"services: ", "scaling: ", `minSize "one"`} {
+		if !slices.ContainsFunc(configErr.Problems, func(p string) bool { return strings.HasPrefix(p, want) }) {
+			t.Errorf("problems %q include none starting %q", configErr.Problems, want)
+		}
+	}
+}
+
+func TestStackNatModes(t *testing.T) {
+	tests := []struct {
+		mode        string
+		natGateways int
+		natInstance bool
+		natRoutes   int
+		// routes maps each private subnet's association to its route table.
+		routes map[string]string
+	}{
+		{
+			mode:   "none",
+			routes: map[string]string{"privateSubnet3-RouteTableAssociation": "MyPrivateRouteTable_id"},
+		},
+		{
+			mode:        "single",
+			natGateways: 1,
+			natRoutes:   1,
+			routes:      map[string]string{"privateSubnet3-RouteTableAssociation": "MyPrivateRouteTable_id"},
+		},
+		{
+			mode:        "perAz",
+			natGateways: 3,
+			natRoutes:   3,
+			routes: map[string]string{
+				"privateSubnet1-RouteTableAssociation": "MyPrivateRouteTable-us-east-1a_id",
+				"privateSubnet3-RouteTableAssociation": "MyPrivateRouteTable-us-east-1c_id",
+			},
+		},
+		{
+			mode:        "instance",
+			natInstance: true,
+			natRoutes:   1,
+			routes:      map[string]string{"privateSubnet3-RouteTableAssociation": "MyPrivateRouteTable_id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			m := newMocks("us-east-1a", "us-east-1b", "us-east-1c")
+			cfg := testConfig()
+			cfg["network:natMode"] = tt.mode
+			if err := runStack(m, cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := len(m.ofType("aws:ec2/natGateway:NatGateway")); got != tt.natGateways {
+				t.Errorf("got %d NAT gateways, want %d", got, tt.natGateways)
+			}
+			for association, routeTable := range tt.routes {
+				if got := m.inputs(t, association)["routeTableId"].StringValue(); got != routeTable {
+					t.Errorf("%s uses route table %s, want %s", association, got, routeTable)
+				}
+			}
+
+			// Every NAT route comes on top of the public internet route
+			if routes := m.ofType("aws:ec2/route:Route"); len(routes) != 1+tt.natRoutes {
+				t.Errorf("got routes %v, want %d NAT routes", routes, tt.natRoutes)
+			}
+
+			if tt.natInstance {
+				in := m.inputs(t, "natInstance")
+				if in["sourceDestCheck"].BoolValue() {
+					t.Error("NAT instance has sourceDestCheck enabled")
+				}
+				route := m.inputs(t, "privateNatRoute-1")
+				if got := route["networkInterfaceId"].StringValue(); got != "natInstance-eni" {
+					t.Errorf("NAT route targets %s, want natInstance-eni", got)
+				}
+			}
+		})
+	}
+}
+
+func TestStackPrivateAppInstances(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:privateAppInstances"] = "true"
+	cfg["network:natMode"] = "single"
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	subnets := func(name string, key resource.PropertyKey) string {
+		var ids []string
+		for _, id := range m.inputs(t, name)[key].ArrayValue() {
+			ids = append(ids, id.StringValue())
+		}
+		return fmt.Sprint(ids)
+	}
+	if got, want := subnets("asg", "vpcZoneIdentifiers"), "[privateSubnet-us-east-1a_id privateSubnet-us-east-1b_id]"; got != want {
+		t.Errorf("asg subnets = %s, want %s", got, want)
+	}
+	if got, want := subnets("testloadBalancer", "subnets"), "[publicSubnet-us-east-1a_id publicSubnet-us-east-1b_id]"; got != want {
+		t.Errorf("load balancer subnets = %s, want %s", got, want)
+	}
+	nic := m.inputs(t, "launchTemplate")["networkInterfaces"].ArrayValue()[0].ObjectValue()
+	if got := nic["associatePublicIpAddress"].StringValue(); got != "false" {
+		t.Errorf("launch template associatePublicIpAddress = %s, want false", got)
+	}
+}
+
+func TestStackPrivateAppInstancesNeedEgress(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:privateAppInstances"] = "true"
+	err := runStack(m, cfg)
+	if err == nil || !strings.Contains(err.Error(), "privateAppInstances needs egress") {
+		t.Errorf("got error %v, want one about egress for private instances", err)
+	}
+}
+
+func TestStackPrivateAppInstancesNeedSecretsManager(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:privateAppInstances"] = "true"
+	cfg["network:interfaceEndpoints"] = `["sns", "logs"]`
+	err := runStack(m, cfg)
+	if err == nil || !strings.Contains(err.Error(), "needs the secretsmanager interface endpoint") {
+		t.Errorf("got error %v, want one about the secretsmanager endpoint", err)
+	}
+}
+
+func TestStackPrivateAppInstancesInRegionsNeedNat(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:privateAppInstances"] = "true"
+	cfg["network:interfaceEndpoints"] = `["sns", "logs", "secretsmanager"]`
+	cfg["network:regions"] = `[{"region": "us-west-2", "cidrBlockAddr": "10.3.0.0/16",
+		"certificateArn": "arn:aws:acm:us-west-2:123456789012:certificate/west"}]`
+	err := runStack(m, cfg)
+	if err == nil || !strings.Contains(err.Error(), "privateAppInstances with regions needs natMode") {
+		t.Errorf("got error %v, want one about NAT for private instances in further regions", err)
+	}
+
+	m = newMocks("us-east-1a", "us-east-1b")
+	cfg["network:natMode"] = "single"
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStackVpcEndpoints(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:privateAppInstances"] = "true"
+	cfg["network:gatewayEndpoints"] = `["dynamodb", "s3"]`
+	cfg["network:interfaceEndpoints"] = `["sns", "logs", "ssm", "sts", "secretsmanager"]`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(m.ofType("aws:ec2/vpcEndpoint:VpcEndpoint")); got != 7 {
+		t.Errorf("got %d VPC endpoints, want 7", got)
+	}
+
+	dynamodb := m.inputs(t, "vpcEndpoint-dynamodb")
+	if got := dynamodb["serviceName"].StringValue(); got != "com.amazonaws.us-east-1.dynamodb" {
+		t.Errorf("dynamodb endpoint service = %s", got)
+	}
+	if got := len(dynamodb["routeTableIds"].ArrayValue()); got != 2 {
+		t.Errorf("dynamodb endpoint is attached to %d route tables, want 2", got)
+	}
+
+	sns := m.inputs(t, "vpcEndpoint-sns")
+	if got := sns["vpcEndpointType"].StringValue(); got != "Interface" {
+		t.Errorf("sns endpoint type = %s, want Interface", got)
+	}
+	var subnets []string
+	for _, id := range sns["subnetIds"].ArrayValue() {
+		subnets = append(subnets, id.StringValue())
+	}
+	if got, want := fmt.Sprint(subnets), "[privateSubnet-us-east-1a_id privateSubnet-us-east-1b_id]"; got != want {
+		t.Errorf("sns endpoint subnets = %s, want %s", got, want)
+	}
+	if got := sns["securityGroupIds"].ArrayValue()[0].StringValue(); got != "vpcEndpointSecurityGroup_id" {
+		t.Errorf("sns endpoint security group = %s", got)
+	}
+	if !sns["privateDnsEnabled"].BoolValue() {
+		t.Error("sns endpoint does not enable private DNS")
+	}
+
+	// Private DNS on interface endpoints needs both VPC DNS attributes
+	vpc := m.inputs(t, "MyVpc")
+	for _, key := range []resource.PropertyKey{"enableDnsSupport", "enableDnsHostnames"} {
+		if !vpc[key].BoolValue() {
+			t.Errorf("VPC %s = %v, want true", key, vpc[key])
+		}
+	}
+}
+
+func TestStackDatabasePasswordGenerated(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	rdsInputs := m.inputs(t, "rdsinstance")
+	if _, ok := rdsInputs["manageMasterUserPassword"]; ok {
+		t.Error("RDS should not manage the password, as its secret would rotate")
+	}
+	password := secretString(rdsInputs["password"])
+	if len(password) != 32 {
+		t.Errorf("RDS password %q is not a generated 32 character one", password)
+	}
+
+	version := m.inputs(t, "dbPasswordSecretVersion")
+	if !version["secretString"].IsSecret() {
+		t.Error("secret version value is not marked secret")
+	}
+	if got, want := secretString(version["secretString"]), `{"password":"`+password+`","username":"csye6225"}`; got != want {
+		t.Errorf("secret string = %s, want %s", got, want)
+	}
+	if got := len(m.ofType("aws:secretsmanager/secretRotation:SecretRotation")); got != 0 {
+		t.Errorf("got %d secret rotations, want none", got)
+	}
+
+	policy := m.inputs(t, "dbSecretReadPolicy")["policy"].StringValue()
+	if !strings.Contains(policy, "arn:mock:dbPasswordSecret") {
+		t.Errorf("instance role policy does not grant the password secret:\n%s", policy)
+	}
+	userData := launchTemplateUserData(t, m, "launchTemplate")
+	if !strings.Contains(userData, "arn:mock:dbPasswordSecret") {
+		t.Errorf("user data does not fetch the password secret:\n%s", userData)
+	}
+	if strings.Contains(userData, password) {
+		t.Error("user data embeds the database password")
+	}
+}
+
+func TestStackUserData(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:logGroupName"] = "webapp"
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	userData := launchTemplateUserData(t, m, "launchTemplate")
+	if !strings.HasPrefix(userData, "#cloud-config\n") {
+		t.Errorf("user data is not a cloud-config document:\n%s", userData)
+	}
+	for _, want := range []string{
+		`user: "csye6225"`,
+		`host: "db.example.internal"`,
+		"port: 3306",
+		`db: "csye6225"`,
+		`snsarn: "arn:aws:sns:us-east-1:123456789012:`,
+		`snsregion: "us-east-1"`,
+		"\n      region: \"us-east-1\"",
+		`loggroup: "webapp"`,
+	} {
+		if !strings.Contains(userData, want) {
+			t.Errorf("user data lacks %q:\n%s", want, userData)
+		}
+	}
+	if strings.Contains(userData, ">>") {
+		t.Errorf("user data appends to files, so reruns duplicate settings:\n%s", userData)
+	}
+}
+
+func TestStackDatabasePasswordFromConfig(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:dbPassword"] = "correct-horse"
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	rdsInputs := m.inputs(t, "rdsinstance")
+	if _, ok := rdsInputs["manageMasterUserPassword"]; ok {
+		t.Error("RDS should not manage the password when one is configured")
+	}
+	if got := secretString(rdsInputs["password"]); got != "correct-horse" {
+		t.Errorf("RDS password = %q", got)
+	}
+
+	version := m.inputs(t, "dbPasswordSecretVersion")
+	if !version["secretString"].IsSecret() {
+		t.Error("secret version value is not marked secret")
+	}
+	if got, want := secretString(version["secretString"]), `{"password":"correct-horse","username":"csye6225"}`; got != want {
+		t.Errorf("secret string = %s, want %s", got, want)
+	}
+
+	policy := m.inputs(t, "dbSecretReadPolicy")["policy"].StringValue()
+	if !strings.Contains(policy, "arn:mock:dbPasswordSecret") {
+		t.Errorf("instance role policy does not grant the password secret:\n%s", policy)
+	}
+	if userData := launchTemplateUserData(t, m, "launchTemplate"); strings.Contains(userData, "correct-horse") {
+		t.Error("user data embeds the database password")
+	}
+}
+
+func TestStackSecurityGroupRules(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b", "us-east-1c")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []struct {
+		name     string
+		typ      string
+		port     float64
+		sourceSG string
+	}{
+		{"ingressRule-0", "ingress", 8080, "lbSecurityGroup_id"},
+		{"ingressRule-1", "ingress", 22, "lbSecurityGroup_id"},
+		{"dbSecurityGroupRule", "ingress", 3306, "application security group_id"},
+	}
+	for _, r := range rules {
+		in := m.inputs(t, r.name)
+		if got := in["type"].StringValue(); got != r.typ {
+			t.Errorf("%s: type = %s, want %s", r.name, got, r.typ)
+		}
+		if got := in["fromPort"].NumberValue(); got != r.port {
+			t.Errorf("%s: fromPort = %v, want %v", r.name, got, r.port)
+		}
+		if got := in["sourceSecurityGroupId"].StringValue(); got != r.sourceSG {
+			t.Errorf("%s: sourceSecurityGroupId = %s, want %s", r.name, got, r.sourceSG)
+		}
+	}
+
+	var lbPorts []float64
+	for _, ingress := range m.inputs(t, "lbSecurityGroup")["ingress"].ArrayValue() {
+		lbPorts = append(lbPorts, ingress.ObjectValue()["fromPort"].NumberValue())
+	}
+	if fmt.Sprint(lbPorts) != "[80 443]" {
+		t.Errorf("load balancer ingress ports = %v, want [80 443]", lbPorts)
+	}
+}
+
+func TestStackListenerPorts(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b", "us-east-1c")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	in := m.inputs(t, "myListenerALB")
+	if got := in["port"].NumberValue(); got != 443 {
+		t.Errorf("listener port = %v, want 443", got)
+	}
+	if got := in["protocol"].StringValue(); got != "HTTPS" {
+		t.Errorf("listener protocol = %s, want HTTPS", got)
+	}
+	if got := m.inputs(t, "testTargetgroup")["port"].NumberValue(); got != 8080 {
+		t.Errorf("target group port = %v, want 8080", got)
+	}
+}
+
+func TestStackDomainAndCertificate(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	record := m.inputs(t, "record")
+	if got := record["name"].StringValue(); got != "app.example.com" {
+		t.Errorf("record name = %s, want app.example.com", got)
+	}
+	if got := record["zoneId"].StringValue(); got != "ZCONFIGURED" {
+		t.Errorf("record zone = %s, want ZCONFIGURED", got)
+	}
+	if got := m.inputs(t, "myListenerALB")["certificateArn"].StringValue(); got != "arn:aws:acm:us-east-1:123456789012:certificate/existing" {
+		t.Errorf("listener certificate = %s", got)
+	}
+	if got := len(m.ofType("aws:acm/certificate:Certificate")); got != 0 {
+		t.Errorf("requested %d certificates despite certificateArn", got)
+	}
+}
+
+func TestStackCreatedCertificateAndZoneLookup(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	delete(cfg, "network:hostedZoneId")
+	delete(cfg, "network:certificateArn")
+	cfg["network:hostedZoneName"] = "example.com."
+	cfg["network:createCertificate"] = "true"
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.inputs(t, "record")["zoneId"].StringValue(); got != "ZLOOKEDUP" {
+		t.Errorf("record zone = %s, want the looked up ZLOOKEDUP", got)
+	}
+	if got := m.inputs(t, "certificate")["validationMethod"].StringValue(); got != "DNS" {
+		t.Errorf("certificate validation method = %s, want DNS", got)
+	}
+	validationRecord := m.inputs(t, "certificateValidationRecord")
+	if got := validationRecord["name"].StringValue(); got != "_token.app.example.com." {
+		t.Errorf("validation record name = %s", got)
+	}
+	if got := validationRecord["zoneId"].StringValue(); got != "ZLOOKEDUP" {
+		t.Errorf("validation record zone = %s, want ZLOOKEDUP", got)
+	}
+	if got := m.inputs(t, "myListenerALB")["certificateArn"].StringValue(); got != "arn:mock:certificate" {
+		t.Errorf("listener certificate = %s, want the created certificate", got)
+	}
+}
+
+func TestStackCreatedCertificatesShareValidationRecord(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	delete(cfg, "network:certificateArn")
+	cfg["network:createCertificate"] = "true"
+	cfg["network:regions"] = `[{"region": "us-west-2", "cidrBlockAddr": "10.3.0.0/16"}]`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each region has its own certificate, but only the home region writes
+	// the validation record, so removing us-west-2 cannot delete it
+	if got := m.ofType("aws:acm/certificate:Certificate"); fmt.Sprint(got) != "[certificate us-west-2-certificate]" {
+		t.Errorf("certificates = %v, want one per region", got)
+	}
+	var records []string
+	for _, name := range m.ofType("aws:route53/record:Record") {
+		if strings.Contains(name, "ValidationRecord") {
+			records = append(records, name)
+		}
+	}
+	if fmt.Sprint(records) != "[certificateValidationRecord]" {
+		t.Errorf("validation records = %v, want only the home region's", records)
+	}
+	fqdns := m.inputs(t, "us-west-2-certificateValidation")["validationRecordFqdns"].ArrayValue()
+	if len(fqdns) != 1 || fqdns[0].StringValue() != "_token.app.example.com." {
+		t.Errorf("us-west-2 validation record FQDNs = %v, want the home region's record", fqdns)
+	}
+}
+
+func TestStackTlsPolicyAndSniCertificates(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.inputs(t, "myListenerALB")["sslPolicy"].StringValue(); got != "ELBSecurityPolicy-TLS13-1-2-2021-06" {
+		t.Errorf("default ssl policy = %s", got)
+	}
+
+	m = newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:sslPolicy"] = "ELBSecurityPolicy-TLS13-1-3-2021-06"
+	cfg["network:additionalCertificateArns"] = `["arn:aws:acm:us-east-1:123456789012:certificate/api", "arn:aws:acm:us-east-1:123456789012:certificate/www"]`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.inputs(t, "myListenerALB")["sslPolicy"].StringValue(); got != "ELBSecurityPolicy-TLS13-1-3-2021-06" {
+		t.Errorf("ssl policy = %s", got)
+	}
+	for i, want := range []string{"api", "www"} {
+		in := m.inputs(t, fmt.Sprintf("listenerCertificate-%d", i+1))
+		if got := in["certificateArn"].StringValue(); !strings.HasSuffix(got, "/"+want) {
+			t.Errorf("listenerCertificate-%d = %s, want the %s certificate", i+1, got, want)
+		}
+		if got := in["listenerArn"].StringValue(); got != "arn:mock:myListenerALB" {
+			t.Errorf("listenerCertificate-%d is on listener %s", i+1, got)
+		}
+	}
+}
+
+func TestStackRejectsWeakTls(t *testing.T) {
+	cfg := testConfig()
+	cfg["network:sslPolicy"] = "ELBSecurityPolicy-2016-08"
+	cfg["network:additionalCertificateArns"] = `["arn:aws:acm:us-east-1:123456789012:certificate/existing"]`
+	err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if len(configErr.Problems) != 2 {
+		t.Errorf("got %d problems, want 2: %v", len(configErr.Problems), configErr.Problems)
+	}
+}
+
+func TestStackServiceRouting(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:services"] = `[
+		{"name": "api", "port": 9000, "pathPatterns": ["/api/*"], "priority": 10},
+		{"name": "admin", "port": 9100, "healthCheckPath": "/admin/health",
+		 "hostPatterns": ["admin.example.com"], "pathPatterns": ["/*"], "priority": 20}
+	]`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	api := m.inputs(t, "apiTargetGroup")
+	if got := api["port"].NumberValue(); got != 9000 {
+		t.Errorf("api target group port = %v, want 9000", got)
+	}
+	if got := api["healthCheck"].ObjectValue()["path"].StringValue(); got != "/healthz" {
+		t.Errorf("api health check path = %s, want the /healthz default", got)
+	}
+	if got := m.inputs(t, "adminTargetGroup")["healthCheck"].ObjectValue()["path"].StringValue(); got != "/admin/health" {
+		t.Errorf("admin health check path = %s", got)
+	}
+
+	rule := m.inputs(t, "adminListenerRule")
+	if got := rule["listenerArn"].StringValue(); got != "arn:mock:myListenerALB" {
+		t.Errorf("admin rule is on listener %s, want the HTTPS listener", got)
+	}
+	if got := rule["priority"].NumberValue(); got != 20 {
+		t.Errorf("admin rule priority = %v, want 20", got)
+	}
+	if got := rule["actions"].ArrayValue()[0].ObjectValue()["targetGroupArn"].StringValue(); got != "arn:mock:adminTargetGroup" {
+		t.Errorf("admin rule forwards to %s", got)
+	}
+	conditions := rule["conditions"].ArrayValue()
+	if len(conditions) != 2 {
+		t.Fatalf("admin rule has %d conditions, want host and path", len(conditions))
+	}
+	if got := conditions[0].ObjectValue()["hostHeader"].ObjectValue()["values"].ArrayValue()[0].StringValue(); got != "admin.example.com" {
+		t.Errorf("admin rule host = %s", got)
+	}
+
+	for _, service := range []string{"api", "admin"} {
+		attachment := m.inputs(t, service+"TargetGroupAttachment")
+		if got := attachment["lbTargetGroupArn"].StringValue(); got != "arn:mock:"+service+"TargetGroup" {
+			t.Errorf("%s attachment target group = %s", service, got)
+		}
+	}
+	var appPorts []float64
+	for _, name := range m.ofType("aws:ec2/securityGroupRule:SecurityGroupRule") {
+		if strings.HasPrefix(name, "ingressRule-") {
+			appPorts = append(appPorts, m.inputs(t, name)["fromPort"].NumberValue())
+		}
+	}
+	sort.Float64s(appPorts)
+	if got := fmt.Sprint(appPorts); got != "[22 8080 9000 9100]" {
+		t.Errorf("app ingress ports = %s, want [22 8080 9000 9100]", got)
+	}
+}
+
+func TestStackRejectsInvalidServices(t *testing.T) {
+	cfg := testConfig()
+	cfg["network:services"] = `[
+		{"name": "api", "port": 9000, "priority": 10},
+		{"name": "api", "port": 70000, "pathPatterns": ["/a", "/b", "/c", "/d", "/e", "/f"], "priority": 10},
+		{"name": "reporting-jobs", "port": 9100, "pathPatterns": ["/reports/*"], "priority": 20}
+	]`
+	err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	// api needs patterns; the second api is a duplicate name, bad port,
+	// too many patterns and a reused priority; reporting-jobs would make a
+	// target group name longer than 32 characters
+	if len(configErr.Problems) != 6 {
+		t.Errorf("got %d problems, want 6: %v", len(configErr.Problems), configErr.Problems)
+	}
+}
+
+func TestStackTargetGroupSettings(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:appTargetGroup"] = `{"healthCheckInterval": 15, "healthCheckTimeout": 10, "healthCheckMatcher": "200-299"}`
+	cfg["network:services"] = `[{"name": "api", "port": 9000, "pathPatterns": ["/api/*"], "priority": 10,
+		"healthyThreshold": 3, "slowStart": 60, "deregistrationDelay": 0, "stickinessDuration": 3600}]`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	app := m.inputs(t, "testTargetgroup")
+	check := app["healthCheck"].ObjectValue()
+	if got := fmt.Sprintf("%s %v %v %s %v", check["path"].StringValue(), check["interval"].NumberValue(), check["timeout"].NumberValue(),
+		check["matcher"].StringValue(), check["healthyThreshold"].NumberValue()); got != "/healthz 15 10 200-299 5" {
+		t.Errorf("app health check = %s, want /healthz 15 10 200-299 5", got)
+	}
+	if got := app["deregistrationDelay"].NumberValue(); got != 300 {
+		t.Errorf("app deregistration delay = %v, want the 300 default", got)
+	}
+	appStickiness := app["stickiness"].ObjectValue()
+	if appStickiness["enabled"].BoolValue() {
+		t.Error("app target group should not be sticky by default")
+	}
+	if _, ok := appStickiness["cookieDuration"]; ok {
+		t.Error("app target group should not set a cookie duration without stickiness")
+	}
+
+	api := m.inputs(t, "apiTargetGroup")
+	if got := api["healthCheck"].ObjectValue()["healthyThreshold"].NumberValue(); got != 3 {
+		t.Errorf("api healthy threshold = %v, want 3", got)
+	}
+	if got := api["slowStart"].NumberValue(); got != 60 {
+		t.Errorf("api slow start = %v, want 60", got)
+	}
+	if got := api["deregistrationDelay"].NumberValue(); got != 0 {
+		t.Errorf("api deregistration delay = %v, want 0", got)
+	}
+	stickiness := api["stickiness"].ObjectValue()
+	if got := fmt.Sprintf("%v %s", stickiness["enabled"].BoolValue(), stickiness["type"].StringValue()); got != "true lb_cookie" {
+		t.Errorf("api stickiness enabled/type = %s, want true lb_cookie", got)
+	}
+	if got := stickiness["cookieDuration"].NumberValue(); got != 3600 {
+		t.Errorf("api cookie duration = %v, want 3600", got)
+	}
+}
+
+func TestStackRejectsInvalidTargetGroupSettings(t *testing.T) {
+	cfg := testConfig()
+	cfg["network:appTargetGroup"] = `{"healthCheckInterval": 10, "healthCheckTimeout": 10, "healthCheckMatcher": "ok"}`
+	cfg["network:services"] = `[{"name": "api", "port": 9000, "pathPatterns": ["/api/*"], "priority": 10,
+		"healthCheckPath": "healthz", "unhealthyThreshold": 11, "slowStart": 10, "deregistrationDelay": 4000}]`
+	err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if len(configErr.Problems) != 6 {
+		t.Errorf("got %d problems, want 6: %v", len(configErr.Problems), configErr.Problems)
+	}
+}
+
+func TestStackScalingPolicies(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.ofType("aws:autoscaling/policy:Policy"); fmt.Sprint(got) != "[scaleDn scaleUp]" {
+		t.Errorf("default scaling policies = %v, want the simple scaleUp and scaleDn", got)
+	}
+
+	m = newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:scaling"] = `{"policy": "targetRequests"}`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+	tracking := m.inputs(t, "targetTracking")["targetTrackingConfiguration"].ObjectValue()
+	if got := tracking["targetValue"].NumberValue(); got != 1000 {
+		t.Errorf("request target = %v, want the 1000 default", got)
+	}
+	metric := tracking["predefinedMetricSpecification"].ObjectValue()
+	if got := metric["resourceLabel"].StringValue(); got != "app/testloadBalancer/1234/targetgroup/testTargetgroup/5678" {
+		t.Errorf("request count resource label = %s", got)
+	}
+	if got := len(m.ofType("aws:cloudwatch/metricAlarm:MetricAlarm")); got != 0 {
+		t.Errorf("target tracking created %d alarms of its own, want none", got)
+	}
+}
+
+func TestStackStepScalingAndSchedules(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:scaling"] = `{
+		"policy": "step",
+		"steps": [
+			{"from": 60, "to": 80, "adjustment": 1},
+			{"from": 80, "adjustment": 2},
+			{"to": 20, "adjustment": -1}
+		],
+		"schedules": [
+			{"name": "night", "recurrence": "0 20 * * *", "timeZone": "Europe/Berlin", "minSize": 0, "maxSize": 0, "desiredCapacity": 0},
+			{"name": "morning", "recurrence": "0 7 * * 1-5", "minSize": 1}
+		]
+	}`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	out := m.inputs(t, "stepScaleOut")
+	var bands []string
+	for _, step := range out["stepAdjustments"].ArrayValue() {
+		s := step.ObjectValue()
+		upper := "open"
+		if v, ok := s["metricIntervalUpperBound"]; ok && !v.IsNull() {
+			upper = v.StringValue()
+		}
+		bands = append(bands, fmt.Sprintf("%s..%s:%v", s["metricIntervalLowerBound"].StringValue(), upper, s["scalingAdjustment"].NumberValue()))
+	}
+	if got := strings.Join(bands, " "); got != "0..20:1 20..open:2" {
+		t.Errorf("scale-out bands = %s, want 0..20:1 20..open:2", got)
+	}
+	if got := m.inputs(t, "stepScaleOutAlarm")["threshold"].NumberValue(); got != 60 {
+		t.Errorf("scale-out alarm threshold = %v, want 60", got)
+	}
+	inAlarm := m.inputs(t, "stepScaleInAlarm")
+	if got := inAlarm["threshold"].NumberValue(); got != 20 {
+		t.Errorf("scale-in alarm threshold = %v, want 20", got)
+	}
+	if got := inAlarm["comparisonOperator"].StringValue(); got != "LessThanThreshold" {
+		t.Errorf("scale-in alarm comparison = %s", got)
+	}
+
+	night := m.inputs(t, "schedule-night")
+	if got := fmt.Sprintf("%v %v %s", night["minSize"].NumberValue(), night["desiredCapacity"].NumberValue(), night["timeZone"].StringValue()); got != "0 0 Europe/Berlin" {
+		t.Errorf("night schedule = %s, want 0 0 Europe/Berlin", got)
+	}
+	morning := m.inputs(t, "schedule-morning")
+	if got := fmt.Sprint(morning["minSize"].NumberValue(), morning["maxSize"].NumberValue()); got != "1 -1" {
+		t.Errorf("morning schedule min/max = %s, want 1 and unchanged (-1)", got)
+	}
+	if got := m.ignoredChanges(t, "asg"); !slices.Equal(got, []string{"desiredCapacity", "maxSize", "minSize"}) {
+		t.Errorf("group ignoreChanges with schedules = %v, want desiredCapacity, maxSize and minSize", got)
+	}
+}
+
+func TestStackRejectsInvalidScaling(t *testing.T) {
+	cfg := testConfig()
+	cfg["network:scaling"] = `{
+		"policy": "targetCpu",
+		"targetValue": 150,
+		"steps": [{"from": 60, "adjustment": 1}],
+		"schedules": [{"name": "night", "recurrence": "@daily"}]
+	}`
+	err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if len(configErr.Problems) != 4 {
+		t.Errorf("got %d problems, want 4: %v", len(configErr.Problems), configErr.Problems)
+	}
+}
+
+func TestStackAutoScalingGroupSizing(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	asg := m.inputs(t, "asg")
+	if got := fmt.Sprint(asg["minSize"].NumberValue(), asg["maxSize"].NumberValue(), asg["desiredCapacity"].NumberValue()); got != "1 3 1" {
+		t.Errorf("default min/max/desired = %s, want 1 3 1", got)
+	}
+	if got := m.ignoredChanges(t, "asg"); !slices.Equal(got, []string{"desiredCapacity"}) {
+		t.Errorf("group ignoreChanges = %v, want only desiredCapacity", got)
+	}
+	if got := m.inputs(t, "launchTemplate")["instanceType"].StringValue(); got != "t2.micro" {
+		t.Errorf("default instance type = %s, want t2.micro", got)
+	}
+	if _, ok := asg["mixedInstancesPolicy"]; ok {
+		t.Error("the group should not have a mixed instances policy by default")
+	}
+
+	m = newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:appInstanceType"] = "t3.small"
+	cfg["network:minSize"] = "2"
+	cfg["network:maxSize"] = "6"
+	cfg["network:mixedInstances"] = `{"instanceTypes": ["t3.small", "t3a.small"], "onDemandBaseCapacity": 1}`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+	asg = m.inputs(t, "asg")
+	if got := fmt.Sprint(asg["minSize"].NumberValue(), asg["maxSize"].NumberValue(), asg["desiredCapacity"].NumberValue()); got != "2 6 2" {
+		t.Errorf("min/max/desired = %s, want 2 6 2", got)
+	}
+	if _, ok := asg["launchTemplate"]; ok {
+		t.Error("a group with a mixed instances policy must not set launchTemplate")
+	}
+	policy := asg["mixedInstancesPolicy"].ObjectValue()
+	distribution := policy["instancesDistribution"].ObjectValue()
+	if got := fmt.Sprintf("%v %v %s", distribution["onDemandBaseCapacity"].NumberValue(),
+		distribution["onDemandPercentageAboveBaseCapacity"].NumberValue(),
+		distribution["spotAllocationStrategy"].StringValue()); got != "1 0 price-capacity-optimized" {
+		t.Errorf("instances distribution = %s, want 1 0 price-capacity-optimized", got)
+	}
+	var types []string
+	for _, override := range policy["launchTemplate"].ObjectValue()["overrides"].ArrayValue() {
+		types = append(types, override.ObjectValue()["instanceType"].StringValue())
+	}
+	if got := fmt.Sprint(types); got != "[t3.small t3a.small]" {
+		t.Errorf("instance types = %s, want [t3.small t3a.small]", got)
+	}
+}
+
+func TestStackRejectsInvalidSizing(t *testing.T) {
+	cfg := testConfig()
+	cfg["network:appInstanceType"] = "large"
+	cfg["network:minSize"] = "4"
+	cfg["network:maxSize"] = "2"
+	cfg["network:mixedInstances"] = `{"instanceTypes": [], "onDemandPercentageAboveBase": 120, "spotAllocationStrategy": "cheapest"}`
+	err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if len(configErr.Problems) != 5 {
+		t.Errorf("got %d problems, want 5: %v", len(configErr.Problems), configErr.Problems)
+	}
+}
+
+func TestStackInstanceRefresh(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	asg := m.inputs(t, "asg")
+	if got := asg["launchTemplate"].ObjectValue()["version"].StringValue(); got != "3" {
+		t.Errorf("launch template version = %s, want the latest, 3", got)
+	}
+	refresh := asg["instanceRefresh"].ObjectValue()
+	if got := refresh["strategy"].StringValue(); got != "Rolling" {
+		t.Errorf("instance refresh strategy = %s, want Rolling", got)
+	}
+	preferences := refresh["preferences"].ObjectValue()
+	if got := fmt.Sprintf("%v %s", preferences["minHealthyPercentage"].NumberValue(),
+		preferences["instanceWarmup"].StringValue()); got != "90 300" {
+		t.Errorf("min healthy/warmup = %s, want 90 300", got)
+	}
+	if _, ok := preferences["checkpointPercentages"]; ok {
+		t.Error("there should be no checkpoints by default")
+	}
+
+	m = newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:instanceRefresh"] = `{"minHealthyPercentage": 50, "instanceWarmup": 120, "checkpointPercentages": [25, 100], "checkpointDelay": 600}`
+	cfg["network:mixedInstances"] = `{"instanceTypes": ["t3.small", "t3a.small"]}`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+	asg = m.inputs(t, "asg")
+	spec := asg["mixedInstancesPolicy"].ObjectValue()["launchTemplate"].ObjectValue()["launchTemplateSpecification"].ObjectValue()
+	if got := spec["version"].StringValue(); got != "3" {
+		t.Errorf("mixed instances launch template version = %s, want 3", got)
+	}
+	preferences = asg["instanceRefresh"].ObjectValue()["preferences"].ObjectValue()
+	var checkpoints []float64
+	for _, checkpoint := range preferences["checkpointPercentages"].ArrayValue() {
+		checkpoints = append(checkpoints, checkpoint.NumberValue())
+	}
+	if got := fmt.Sprintf("%v %s %v %s", preferences["minHealthyPercentage"].NumberValue(),
+		preferences["instanceWarmup"].StringValue(), checkpoints,
+		preferences["checkpointDelay"].StringValue()); got != "50 120 [25 100] 600" {
+		t.Errorf("refresh preferences = %s, want 50 120 [25 100] 600", got)
+	}
+
+	// Zero is a valid setting, not a request for the default
+	m = newMocks("us-east-1a", "us-east-1b")
+	cfg = testConfig()
+	cfg["network:instanceRefresh"] = `{"minHealthyPercentage": 0, "instanceWarmup": 0}`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+	preferences = m.inputs(t, "asg")["instanceRefresh"].ObjectValue()["preferences"].ObjectValue()
+	if got := fmt.Sprintf("%v %s", preferences["minHealthyPercentage"].NumberValue(),
+		preferences["instanceWarmup"].StringValue()); got != "0 0" {
+		t.Errorf("min healthy/warmup = %s, want 0 0", got)
+	}
+
+	m = newMocks("us-east-1a", "us-east-1b")
+	cfg = testConfig()
+	cfg["network:instanceRefresh"] = `{"enabled": false}`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.inputs(t, "asg")["instanceRefresh"]; ok {
+		t.Error("a disabled instance refresh should not be set on the group")
+	}
+}
+
+func TestStackRejectsInvalidInstanceRefresh(t *testing.T) {
+	cfg := testConfig()
+	cfg["network:instanceRefresh"] = `{"minHealthyPercentage": 101, "instanceWarmup": -1, "checkpointPercentages": [50, 20]}`
+	err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if len(configErr.Problems) != 4 {
+		t.Errorf("got %d problems, want 4: %v", len(configErr.Problems), configErr.Problems)
+	}
+}
+
+func TestStackBlueGreen(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:deployment"] = `{"mode": "blueGreen", "activeColor": "blue", "amiIds": {"blue": "ami-0aaaaaaaaaaaaaaaa"}}`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.ofType("aws:lb/targetGroup:TargetGroup"); fmt.Sprint(got) != "[blueTargetGroup greenTargetGroup]" {
+		t.Errorf("target groups = %v, want one per color", got)
+	}
+	forward := m.inputs(t, "myListenerALB")["defaultActions"].ArrayValue()[0].ObjectValue()["forward"].ObjectValue()
+	weights := map[string]float64{}
+	for _, targetGroup := range forward["targetGroups"].ArrayValue() {
+		weights[targetGroup.ObjectValue()["arn"].StringValue()] = targetGroup.ObjectValue()["weight"].NumberValue()
+	}
+	if got := fmt.Sprint(weights); got != "map[arn:mock:blueTargetGroup:100 arn:mock:greenTargetGroup:0]" {
+		t.Errorf("listener weights = %s, want all traffic on blue", got)
+	}
+
+	if got := m.ofType("aws:autoscaling/group:Group"); fmt.Sprint(got) != "[asg-blue asg-green]" {
+		t.Fatalf("groups = %v, want one per color", got)
+	}
+	for color, ami := range map[string]string{"blue": "ami-0aaaaaaaaaaaaaaaa", "green": "ami-0123456789abcdef0"} {
+		if got := m.inputs(t, "launchTemplate-"+color)["imageId"].StringValue(); got != ami {
+			t.Errorf("%s AMI = %s, want %s", color, got, ami)
+		}
+		asg := m.inputs(t, "asg-"+color)
+		if got := asg["name"].StringValue(); got != "asg-"+color+"-"+ami {
+			t.Errorf("%s group name = %s, want it to change with the AMI", color, got)
+		}
+		if got := asg["targetGroupArns"].ArrayValue()[0].StringValue(); got != "arn:mock:"+color+"TargetGroup" {
+			t.Errorf("%s group target group = %s, want the %s one", color, got, color)
+		}
+		if got := asg["minElbCapacity"].NumberValue(); got != 1 {
+			t.Errorf("%s group waits for %v healthy instances, want 1", color, got)
+		}
+		m.inputs(t, "scaleUp-"+color)
+	}
+	if got := m.ofType("aws:autoscaling/attachment:Attachment"); len(got) != 0 {
+		t.Errorf("attachments = %v, want none in blue/green mode", got)
+	}
+
+	// Rolling back flips the traffic to the other color
+	m = newMocks("us-east-1a", "us-east-1b")
+	cfg["network:deployment"] = `{"mode": "blueGreen", "activeColor": "green", "amiIds": {"green": "ami-0bbbbbbbbbbbbbbbb"}}`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+	forward = m.inputs(t, "myListenerALB")["defaultActions"].ArrayValue()[0].ObjectValue()["forward"].ObjectValue()
+	for _, targetGroup := range forward["targetGroups"].ArrayValue() {
+		tg := targetGroup.ObjectValue()
+		want := 0.0
+		if tg["arn"].StringValue() == "arn:mock:greenTargetGroup" {
+			want = 100
+		}
+		if got := tg["weight"].NumberValue(); got != want {
+			t.Errorf("weight of %s = %v, want %v", tg["arn"].StringValue(), got, want)
+		}
+	}
+}
+
+func TestStackRejectsInvalidDeployment(t *testing.T) {
+	cfg := testConfig()
+	cfg["network:deployment"] = `{"mode": "canary", "activeColor": "red", "amiIds": {"blue": "ubuntu", "purple": "ami-12345678"}}`
+	err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if len(configErr.Problems) != 4 {
+		t.Errorf("got %d problems, want 4: %v", len(configErr.Problems), configErr.Problems)
+	}
+
+	cfg = testConfig()
+	cfg["network:deployment"] = `{"mode": "blueGreen", "activeColor": "green"}`
+	cfg["network:services"] = `[{"name": "admin", "port": 9090, "hostPatterns": ["admin.example.com"], "priority": 10}]`
+	err = runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if len(configErr.Problems) != 2 {
+		t.Errorf("got %d problems, want 2: %v", len(configErr.Problems), configErr.Problems)
+	}
+}
+
+// amiFilters returns the filters of the last AMI lookup as name=values.
+func (m *mocks) amiFilters(t *testing.T) []string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	args, ok := m.calls["aws:ec2/getAmi:getAmi"]
+	if !ok {
+		t.Fatal("no AMI was looked up")
+	}
+	var filters []string
+	for _, filter := range args["filters"].ArrayValue() {
+		var values []string
+		for _, value := range filter.ObjectValue()["values"].ArrayValue() {
+			values = append(values, value.StringValue())
+		}
+		filters = append(filters, filter.ObjectValue()["name"].StringValue()+"="+strings.Join(values, ","))
+	}
+	return filters
+}
+
+func TestStackAmiSelection(t *testing.T) {
+	tests := []struct {
+		name        string
+		ami         string
+		wantOwners  string
+		wantFilters string
+		wantAmi     string
+	}{
+		{
+			name:        "amiName",
+			wantOwners:  "[self]",
+			wantFilters: "[name=webapp-ami]",
+			wantAmi:     "ami-0123456789abcdef0",
+		},
+		{
+			name:        "lookup",
+			ami:         `{"owners": ["123456789012"], "name": "webapp-*", "tags": {"Role": "webapp", "Branch": "main"}}`,
+			wantOwners:  "[123456789012]",
+			wantFilters: "[name=webapp-* tag:Branch=main tag:Role=webapp]",
+			wantAmi:     "ami-0123456789abcdef0",
+		},
+		{
+			name:        "id",
+			ami:         `{"strategy": "id", "id": "ami-0aaaaaaaaaaaaaaaa"}`,
+			wantOwners:  "[]",
+			wantFilters: "[image-id=ami-0aaaaaaaaaaaaaaaa]",
+			wantAmi:     "ami-0aaaaaaaaaaaaaaaa",
+		},
+		{
+			name:        "ssm",
+			ami:         `{"strategy": "ssm", "ssmParameter": "/webapp/ami", "owners": ["amazon"]}`,
+			wantOwners:  "[amazon]",
+			wantFilters: "[image-id=ami-0fedcba9876543210]",
+			wantAmi:     "ami-0fedcba9876543210",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMocks("us-east-1a", "us-east-1b")
+			cfg := testConfig()
+			if tt.ami != "" {
+				cfg["network:ami"] = tt.ami
+			}
+			if err := runStack(m, cfg); err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(m.amiFilters(t)); got != tt.wantFilters {
+				t.Errorf("AMI filters = %s, want %s", got, tt.wantFilters)
+			}
+			var owners []string
+			for _, owner := range arrayValue(m.calls["aws:ec2/getAmi:getAmi"]["owners"]) {
+				owners = append(owners, owner.StringValue())
+			}
+			if got := fmt.Sprint(owners); got != tt.wantOwners {
+				t.Errorf("AMI owners = %s, want %s", got, tt.wantOwners)
+			}
+			if got := m.inputs(t, "launchTemplate")["imageId"].StringValue(); got != tt.wantAmi {
+				t.Errorf("launch template AMI = %s, want %s", got, tt.wantAmi)
+			}
+		})
+	}
+}
+
+func TestStackRejectsOldAmi(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	m.amiCreationDate = "2024-01-15T10:00:00.000Z"
+	cfg := testConfig()
+	cfg["network:ami"] = `{"maxAgeDays": 30}`
+	err := runStack(m, cfg)
+	if err == nil || !strings.Contains(err.Error(), "more than the 30 days maxAgeDays allows") {
+		t.Fatalf("got error %v, want the AMI rejected as too old", err)
+	}
+	if got := m.ofType("aws:ec2/launchTemplate:LaunchTemplate"); len(got) != 0 {
+		t.Errorf("launch templates %v were created for a rejected AMI", got)
+	}
+
+	m = newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, cfg); err != nil {
+		t.Fatalf("a two day old AMI was rejected: %v", err)
+	}
+}
+
+func TestStackRejectsInvalidAmi(t *testing.T) {
+	cfg := testConfig()
+	cfg["network:ami"] = `{"strategy": "id", "id": "webapp", "owners": ["someone"], "maxAgeDays": -1}`
+	err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if len(configErr.Problems) != 3 {
+		t.Errorf("got %d problems, want 3: %v", len(configErr.Problems), configErr.Problems)
+	}
+
+	cfg = testConfig()
+	cfg["network:ami"] = `{"strategy": "ssm", "ssmParameter": "webapp/ami"}`
+	if err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg); !errors.As(err, &configErr) || len(configErr.Problems) != 1 {
+		t.Errorf("got error %v, want the relative SSM parameter rejected", err)
+	}
+
+	// The name is reported under the key it was set with
+	for key, want := range map[string]string{
+		"network:ami":     `ami.name "x" must be`,
+		"network:amiName": `amiName "x" must be`,
+	} {
+		cfg = testConfig()
+		if key == "network:ami" {
+			cfg[key] = `{"name": "x"}`
+		} else {
+			cfg[key] = "x"
+		}
+		err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got error %v, want one starting %q", key, err, want)
+		}
+	}
+}
+
+func TestStackMultiRegion(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:regions"] = `[{"region": "us-west-2", "cidrBlockAddr": "10.3.0.0/16",
+		"certificateArn": "arn:aws:acm:us-west-2:123456789012:certificate/west"}]`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.ofType("pulumi:providers:aws"); fmt.Sprint(got) != "[provider-us-west-2]" {
+		t.Errorf("providers = %v, want one for us-west-2", got)
+	}
+	// The region's load balancer and target group would be auto-named past
+	// ELB's 32 characters, so AWS names them from a short prefix instead
+	for _, name := range []string{"us-west-2-testloadBalancer", "us-west-2-testTargetgroup"} {
+		if got := m.inputs(t, name)["namePrefix"].StringValue(); got != "usw2-" {
+			t.Errorf("%s namePrefix = %q, want usw2-", name, got)
+		}
+	}
+	for _, name := range []string{"testloadBalancer", "testTargetgroup"} {
+		if _, ok := m.inputs(t, name)["namePrefix"]; ok {
+			t.Errorf("home %s should keep its auto-generated name", name)
+		}
+	}
+	if got := m.ofType("aws:ec2/vpc:Vpc"); fmt.Sprint(got) != "[MyVpc us-west-2-MyVpc]" {
+		t.Errorf("VPCs = %v, want the home one unchanged and one for us-west-2", got)
+	}
+	if got := m.inputs(t, "us-west-2-MyVpc")["cidrBlock"].StringValue(); got != "10.3.0.0/16" {
+		t.Errorf("us-west-2 VPC CIDR = %s, want 10.3.0.0/16", got)
+	}
+	for _, typ := range []string{"aws:lb/loadBalancer:LoadBalancer", "aws:autoscaling/group:Group", "aws:rds/instance:Instance"} {
+		if got := len(m.ofType(typ)); got != 2 {
+			t.Errorf("%s: got %d resources, want one per region", typ, got)
+		}
+	}
+
+	amiCopy := m.inputs(t, "us-west-2-amiCopy-ami-0123456789abcdef0")
+	if got := amiCopy["sourceAmiId"].StringValue() + " " + amiCopy["sourceAmiRegion"].StringValue(); got != "ami-0123456789abcdef0 us-east-1" {
+		t.Errorf("AMI copy source = %s, want the home AMI in us-east-1", got)
+	}
+	if got := m.inputs(t, "us-west-2-launchTemplate")["imageId"].StringValue(); got != "us-west-2-amiCopy-ami-0123456789abcdef0_id" {
+		t.Errorf("us-west-2 launch template AMI = %s, want the copy", got)
+	}
+	if got := m.inputs(t, "launchTemplate")["imageId"].StringValue(); got != "ami-0123456789abcdef0" {
+		t.Errorf("home launch template AMI = %s, want the original", got)
+	}
+	// The instances run in us-west-2 but publish to the home region's topic
+	userData := launchTemplateUserData(t, m, "us-west-2-launchTemplate")
+	for _, want := range []string{
+		`snsarn: "arn:aws:sns:us-east-1:`,
+		`snsregion: "us-east-1"`,
+		"\n      region: \"us-west-2\"",
+	} {
+		if !strings.Contains(userData, want) {
+			t.Errorf("us-west-2 user data lacks %q:\n%s", want, userData)
+		}
+	}
+	if got := m.inputs(t, "us-west-2-myListenerALB")["certificateArn"].StringValue(); got != "arn:aws:acm:us-west-2:123456789012:certificate/west" {
+		t.Errorf("us-west-2 listener certificate = %s, want the region's own", got)
+	}
+
+	for name, region := range map[string]string{"record": "us-east-1", "us-west-2-record": "us-west-2"} {
+		record := m.inputs(t, name)
+		if got := record["setIdentifier"].StringValue(); got != region {
+			t.Errorf("%s set identifier = %s, want %s", name, got, region)
+		}
+		policies := record["latencyRoutingPolicies"].ArrayValue()
+		if len(policies) != 1 || policies[0].ObjectValue()["region"].StringValue() != region {
+			t.Errorf("%s latency routing = %v, want region %s", name, policies, region)
+		}
+		if !record["aliases"].ArrayValue()[0].ObjectValue()["evaluateTargetHealth"].BoolValue() {
+			t.Errorf("%s should evaluate the load balancer's health", name)
+		}
+	}
+
+	// Failover answers with the stack's own region first
+	m = newMocks("us-east-1a", "us-east-1b")
+	cfg["network:dnsRouting"] = "failover"
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"record": "PRIMARY", "us-west-2-record": "SECONDARY"} {
+		policies := m.inputs(t, name)["failoverRoutingPolicies"].ArrayValue()
+		if len(policies) != 1 || policies[0].ObjectValue()["type"].StringValue() != want {
+			t.Errorf("%s failover routing = %v, want %s", name, policies, want)
+		}
+	}
+}
+
+func TestStackMultiRegionCopiesPinnedAmisOnly(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:regions"] = `[{"region": "us-west-2", "cidrBlockAddr": "10.3.0.0/16",
+		"certificateArn": "arn:aws:acm:us-west-2:123456789012:certificate/west"}]`
+	cfg["network:deployment"] = `{"mode": "blueGreen", "activeColor": "green",
+		"amiIds": {"blue": "ami-0aaaaaaaaaaaaaaaa", "green": "ami-0bbbbbbbbbbbbbbbb"}}`
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both colors are pinned, so the latest AMI runs nowhere and is not copied
+	want := "[us-west-2-amiCopy-ami-0aaaaaaaaaaaaaaaa us-west-2-amiCopy-ami-0bbbbbbbbbbbbbbbb]"
+	if got := m.ofType("aws:ec2/amiCopy:AmiCopy"); fmt.Sprint(got) != want {
+		t.Errorf("AMI copies = %v, want %s", got, want)
+	}
+}
+
+func TestStackSingleRegionRecord(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+	record := m.inputs(t, "record")
+	for _, key := range []resource.PropertyKey{"setIdentifier", "latencyRoutingPolicies", "failoverRoutingPolicies"} {
+		if _, ok := record[key]; ok {
+			t.Errorf("a single-region record should not set %s", key)
+		}
+	}
+	if got := m.ofType("pulumi:providers:aws"); len(got) != 0 {
+		t.Errorf("providers = %v, want none for a single region", got)
+	}
+}
+
+func TestStackRejectsInvalidRegions(t *testing.T) {
+	cfg := testConfig()
+	cfg["network:regions"] = `[
+		{"region": "us-east-1", "certificateArn": "arn:aws:acm:us-east-1:123456789012:certificate/home"},
+		{"region": "eu-west-1", "cidrBlockAddr": "10.3.0.1/16"},
+		{"region": "eu-west-1", "certificateArn": "arn:aws:acm:us-east-1:123456789012:certificate/wrong"},
+		{"region": "mars"},
+		{"region": "us-gov-west-1", "certificateArn": "arn:aws:acm:us-gov-west-1:123456789012:certificate/gov"}
+	]`
+	cfg["network:dnsRouting"] = "failover"
+	err := runStack(newMocks("us-east-1a", "us-east-1b"), cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	// own region; bad CIDR and no certificate; duplicate and certificate in
+	// the wrong region; bad name and no certificate; a GovCloud region;
+	// failover over 5 regions
+	if len(configErr.Problems) != 9 {
+		t.Errorf("got %d problems, want 9: %v", len(configErr.Problems), configErr.Problems)
+	}
+}
+
+func TestRegionCode(t *testing.T) {
+	for region, want := range map[string]string{
+		"us-east-1":      "use1",
+		"us-west-2":      "usw2",
+		"eu-central-1":   "euc1",
+		"ap-southeast-2": "apse2",
+	} {
+		if got := regionCode(region); got != want {
+			t.Errorf("regionCode(%s) = %s, want %s", region, got, want)
+		}
+	}
+}
+
+func TestStackHttpRedirect(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	in := m.inputs(t, "httpRedirectListener")
+	if got := in["port"].NumberValue(); got != 80 {
+		t.Errorf("redirect listener port = %v, want 80", got)
+	}
+	action := in["defaultActions"].ArrayValue()[0].ObjectValue()
+	redirect := action["redirect"].ObjectValue()
+	if got := action["type"].StringValue(); got != "redirect" {
+		t.Errorf("redirect listener action = %s, want redirect", got)
+	}
+	if got := redirect["protocol"].StringValue() + ":" + redirect["port"].StringValue(); got != "HTTPS:443" {
+		t.Errorf("redirect target = %s, want HTTPS:443", got)
+	}
+	if got := redirect["statusCode"].StringValue(); got != "HTTP_301" {
+		t.Errorf("redirect status = %s, want HTTP_301", got)
+	}
+}
+
+func TestStackHttpPortClosed(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b")
+	cfg := testConfig()
+	cfg["network:httpRedirect"] = "false"
+	cfg["network:closeHttpPort"] = "true"
+	if err := runStack(m, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.ofType("aws:lb/listener:Listener"); fmt.Sprint(got) != "[myListenerALB]" {
+		t.Errorf("listeners = %v, want only the HTTPS one", got)
+	}
+	ingress := m.inputs(t, "lbSecurityGroup")["ingress"].ArrayValue()
+	if len(ingress) != 1 || ingress[0].ObjectValue()["fromPort"].NumberValue() != 443 {
+		t.Errorf("load balancer ingress = %v, want only 443", ingress)
+	}
+}
+
+func TestStackLambdaEnvironment(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b", "us-east-1c")
+	if err := runStack(m, testConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	env := m.inputs(t, "myLambdaFunction")["environment"].ObjectValue()["variables"].ObjectValue()
+	want := map[string]string{
+		"GCPKEY":      "private-key",
+		"GCBUCKET":    "test-bucket",
+		"DYNAMOTB":    "dynamotbl-table",
+		"MANDRILLKEY": "mandrill",
+	}
+	for key, value := range want {
+		v, ok := env[resource.PropertyKey(key)]
+		if !ok {
+			t.Errorf("lambda env %s is not set", key)
+			continue
+		}
+		if got := secretString(v); got != value {
+			t.Errorf("lambda env %s = %q, want %q", key, got, value)
+		}
+		if secret := key == "GCPKEY" || key == "MANDRILLKEY"; secret != v.IsSecret() {
+			t.Errorf("lambda env %s: secret = %v, want %v", key, v.IsSecret(), secret)
+		}
+	}
+}
+
+func TestStackRejectsInvalidConfig(t *testing.T) {
+	m := newMocks("us-east-1a", "us-east-1b", "us-east-1c")
+	cfg := testConfig()
+	cfg["network:cidrBlockAddr"] = "10.2.0.0/33"
+	cfg["network:amiName"] = "x"
+	cfg["network:hostedZoneName"] = "other.com"
+	cfg["network:createCertificate"] = "true"
+
+	err := runStack(m, cfg)
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("got error %v, want a *ConfigError", err)
+	}
+	if len(configErr.Problems) != 4 {
+		t.Errorf("got %d problems, want 4: %v", len(configErr.Problems), configErr.Problems)
+	}
+	if got := len(m.ofType("aws:ec2/vpc:Vpc")); got != 0 {
+		t.Errorf("registered %d VPCs despite invalid config", got)
+	}
+}
+
+// secretString unwraps a string property that may be marked secret.
+func secretString(v resource.PropertyValue) string {
+	if v.IsSecret() {
+		return v.SecretValue().Element.StringValue()
+	}
+	return v.StringValue()
+}
+
+// arrayValue returns the elements of v, or none when v is unset.
+func arrayValue(v resource.PropertyValue) []resource.PropertyValue {
+	if !v.IsArray() {
+		return nil
+	}
+	return v.ArrayValue()
+}
+
+// launchTemplateUserData decodes the user data of the named launch template.
+func launchTemplateUserData(t *testing.T, m *mocks, name string) string {
+	t.Helper()
+	userData, err := base64.StdEncoding.DecodeString(m.inputs(t, name)["userData"].StringValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(userData)
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/dspinhirne/netaddr-go"
+
+	"pulumi-infra-setup/components"
+)
+
+// amiNamePattern mirrors the characters EC2 accepts in an image name, plus
+// the * and ? wildcards of a lookup.
+var amiNamePattern = regexp.MustCompile(`^[a-zA-Z0-9()\[\]./\-'@_ *?]{3,128}$`)
+
+// amiOwnerPattern matches the image owners a lookup accepts: an account ID
+// or one of the aliases.
+var amiOwnerPattern = regexp.MustCompile(`^(self|amazon|aws-marketplace|[0-9]{12})$`)
+
+// regionPattern matches commercial AWS region names like us-east-1. GovCloud
+// regions are a separate partition the stack's ARNs do not cover.
+var regionPattern = regexp.MustCompile(`^[a-z]{2}-[a-z]+-[0-9]$`)
+
+// ssmParameterPattern matches fully qualified SSM parameter names.
+var ssmParameterPattern = regexp.MustCompile(`^/[a-zA-Z0-9_.\-/]+$`)
+
+// serviceNamePattern keeps service names short enough for their target
+// groups: "<service>TargetGroup" plus the 8 character random suffix Pulumi
+// appends must fit the 32 characters ELB allows.
+var serviceNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]{0,12}$`)
+
+// instanceTypePattern matches EC2 instance type names like t3.micro or m7g.2xlarge.
+var instanceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*\.[a-z0-9]+$`)
+
+// amiIdPattern matches EC2 image IDs, in the short and the long format.
+var amiIdPattern = regexp.MustCompile(`^ami-([0-9a-f]{8}|[0-9a-f]{17})$`)
+
+// scheduleNamePattern matches the scheduled action names AWS accepts.
+var scheduleNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,255}$`)
+
+// legacyTlsPolicies are ELB security policies still accepting TLS 1.0 or 1.1.
+var legacyTlsPolicies = []string{
+	"ELBSecurityPolicy-2016-08",
+	"ELBSecurityPolicy-2015-05",
+	"ELBSecurityPolicy-TLS-1-0-2015-04",
+	"ELBSecurityPolicy-TLS-1-1-2017-01",
+	"ELBSecurityPolicy-FS-2018-06",
+	"ELBSecurityPolicy-FS-1-1-2019-08",
+	"ELBSecurityPolicy-TLS13-1-0-2021-06",
+	"ELBSecurityPolicy-TLS13-1-1-2021-06",
+}
+
+// ConfigError lists every problem found in a stack's network configuration.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid network configuration (%d problems):", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - network:")
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// validate checks every Config.Network field before any resource is
+// registered. available lists the availability zones of home, the stack's
+// own region. All problems are reported together.
+func (c Config) validate(available []string, home string) error {
+	problems := slices.Clone(c.invalid)
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	n := c.Network
+	names := []struct{ key, value string }{
+		{"vpcName", n.VPCName},
+		{"internetGatewayName", n.InternetGateWayName},
+		{"internetGatewayAttachmentName", n.InternetGatewayAttachmentName},
+		{"publicRouteTableName", n.PublicRouteTableName},
+		{"privateRouteTableName", n.PrivateRouteTableName},
+		{"publicRouteName", n.PublicRouteName},
+	}
+	for _, name := range names {
+		if strings.TrimSpace(name.value) == "" {
+			addf("%s must not be empty", name.key)
+		}
+	}
+
+	switch {
+	case len(n.AvailabilityZones) > 0:
+		seen := map[string]bool{}
+		for _, az := range n.AvailabilityZones {
+			if seen[az] {
+				addf("availabilityZones lists %s more than once", az)
+			} else if !slices.Contains(available, az) {
+				addf("availabilityZones: %s is not available in this region (have %s)", az, strings.Join(available, ", "))
+			}
+			seen[az] = true
+		}
+	case n.AzCount < 0:
+		addf("azCount %d must not be negative", n.AzCount)
+	case n.AzCount > len(available):
+		addf("azCount %d exceeds the %d availability zones in this region", n.AzCount, len(available))
+	}
+	// An application load balancer needs subnets in at least two zones.
+	if zones := c.zones(available); len(zones) < components.MinLoadBalancerZones {
+		addf("the load balancer needs public subnets in at least %d availability zones, but only %d (%s) would be used",
+			components.MinLoadBalancerZones, len(zones), strings.Join(zones, ", "))
+	}
+
+	tiersValid := true
+	hasPublic, hasPrivate := false, false
+	tierNames := map[string]bool{}
+	for i, tier := range n.SubnetTiers {
+		switch {
+		case strings.TrimSpace(tier.Name) == "":
+			addf("subnetTiers[%d].name must not be empty", i)
+			tiersValid = false
+		case tierNames[tier.Name]:
+			addf("subnetTiers[%d].name %q is used by another tier", i, tier.Name)
+			tiersValid = false
+		}
+		tierNames[tier.Name] = true
+		switch tier.Kind {
+		case components.TierPublic:
+			hasPublic = true
+		case components.TierPrivate:
+			hasPrivate = true
+		case components.TierIsolated:
+		default:
+			addf("subnetTiers[%d].kind %q must be one of public, private or isolated", i, tier.Kind)
+			tiersValid = false
+		}
+		if tier.PrefixLength > 28 {
+			addf("subnetTiers[%d] (%s): /%d is smaller than the /28 minimum AWS allows", i, tier.Name, tier.PrefixLength)
+			tiersValid = false
+		}
+	}
+	if !hasPublic {
+		addf("subnetTiers must include a public tier for the load balancer")
+	}
+
+	switch n.NatMode {
+	case components.NatNone, components.NatSingle, components.NatPerAz, components.NatInstance:
+	default:
+		addf("natMode %q must be one of none, single, perAz or instance", n.NatMode)
+	}
+	if n.PrivateAppInstances {
+		if !hasPrivate {
+			addf("privateAppInstances needs a private subnet tier to place the instances in")
+		}
+		switch {
+		case n.NatMode != components.NatNone:
+		case len(n.Regions) > 0:
+			// Further regions publish to the SNS topic in the stack's region,
+			// which their own interface endpoints do not reach
+			addf("privateAppInstances with regions needs natMode")
+		case len(n.InterfaceEndpoints) == 0:
+			addf("privateAppInstances needs egress for the instances: set natMode or interfaceEndpoints")
+		case !slices.Contains(n.InterfaceEndpoints, "secretsmanager"):
+			// The instances fetch the database password at boot
+			addf("privateAppInstances without natMode needs the secretsmanager interface endpoint")
+		}
+	}
+
+	seenEndpoints := map[string]bool{}
+	for _, service := range n.GatewayEndpoints {
+		if !slices.Contains(components.GatewayEndpointServices, service) {
+			addf("gatewayEndpoints: %q must be one of %s", service, strings.Join(components.GatewayEndpointServices, ", "))
+		}
+		if seenEndpoints[service] {
+			addf("gatewayEndpoints lists %s more than once", service)
+		}
+		seenEndpoints[service] = true
+	}
+	for _, service := range n.InterfaceEndpoints {
+		if strings.TrimSpace(service) == "" {
+			addf("interfaceEndpoints must not contain empty service names")
+		} else if seenEndpoints[service] {
+			addf("interfaceEndpoints: %s is already listed as an endpoint", service)
+		}
+		seenEndpoints[service] = true
+	}
+	if len(n.InterfaceEndpoints) > 0 && !hasPrivate {
+		addf("interfaceEndpoints needs a private subnet tier to place the endpoints in")
+	}
+
+	vpcNet, err := netaddr.ParseIPv4Net(n.CIDRBlockAddr)
+	if n.CIDRBlockAddr == "" {
+		addf("cidrBlockAddr must be set")
+	} else if err != nil {
+		addf("cidrBlockAddr %q is not a valid IPv4 CIDR: %v", n.CIDRBlockAddr, err)
+	} else if vpcNet.String() != n.CIDRBlockAddr {
+		addf("cidrBlockAddr %q is not a network address, did you mean %s?", n.CIDRBlockAddr, vpcNet)
+	} else if tiersValid {
+		// PlanSubnets reports tiers that are too large for the VPC or that
+		// do not all fit in it.
+		if _, err := components.PlanSubnets(n.CIDRBlockAddr, c.zones(available), n.SubnetTiers); err != nil {
+			addf("subnetTiers: %v", err)
+		}
+	}
+
+	if strings.TrimSpace(n.DomainName) == "" {
+		addf("domainName must be set to the name served by the load balancer")
+	}
+	switch {
+	case n.HostedZoneId == "" && n.HostedZoneName == "":
+		addf("hostedZoneId or hostedZoneName must be set to the zone holding domainName")
+	case n.HostedZoneId != "" && n.HostedZoneName != "":
+		addf("set only one of hostedZoneId and hostedZoneName")
+	case n.HostedZoneName != "" && n.DomainName != "":
+		zone := strings.TrimSuffix(n.HostedZoneName, ".")
+		if n.DomainName != zone && !strings.HasSuffix(n.DomainName, "."+zone) {
+			addf("domainName %s is not in hosted zone %s", n.DomainName, n.HostedZoneName)
+		}
+	}
+	switch {
+	case n.CertificateArn == "" && !n.CreateCertificate:
+		addf("certificateArn must be set, or createCertificate enabled, for the HTTPS listener")
+	case n.CertificateArn != "" && n.CreateCertificate:
+		addf("set only one of certificateArn and createCertificate")
+	case n.CertificateArn != "" && !strings.HasPrefix(n.CertificateArn, "arn:aws:acm:"):
+		addf("certificateArn %q is not an ACM certificate ARN", n.CertificateArn)
+	}
+
+	seenCertificates := map[string]bool{n.CertificateArn: true}
+	for i, arn := range n.AdditionalCertificateArns {
+		if !strings.HasPrefix(arn, "arn:aws:acm:") {
+			addf("additionalCertificateArns[%d] %q is not an ACM certificate ARN", i, arn)
+		} else if seenCertificates[arn] {
+			addf("additionalCertificateArns[%d] %s is already on the listener", i, arn)
+		}
+		seenCertificates[arn] = true
+	}
+	if !strings.HasPrefix(n.SslPolicy, "ELBSecurityPolicy-") {
+		addf("sslPolicy %q is not an ELB security policy, e.g. %s", n.SslPolicy, components.DefaultSslPolicy)
+	} else if slices.Contains(legacyTlsPolicies, n.SslPolicy) {
+		addf("sslPolicy %s allows TLS versions older than 1.2", n.SslPolicy)
+	}
+
+	validateTargetGroup("appTargetGroup", n.AppTargetGroup, addf)
+	serviceNames := map[string]bool{}
+	priorities := map[int]string{}
+	for i, service := range n.Services {
+		if !serviceNamePattern.MatchString(service.Name) {
+			addf("services[%d].name %q must be 1-13 letters, digits or dashes, starting with a letter", i, service.Name)
+		} else if serviceNames[service.Name] {
+			addf("services[%d].name %q is used by another service", i, service.Name)
+		}
+		serviceNames[service.Name] = true
+		if service.Port < 1 || service.Port > 65535 {
+			addf("services[%d] (%s): port %d must be between 1 and 65535", i, service.Name, service.Port)
+		}
+		validateTargetGroup(fmt.Sprintf("services[%d] (%s)", i, service.Name), service.TargetGroupSettings, addf)
+		switch patterns := len(service.HostPatterns) + len(service.PathPatterns); {
+		case patterns == 0:
+			addf("services[%d] (%s) needs hostPatterns or pathPatterns to route requests to it", i, service.Name)
+		case patterns > components.MaxServiceConditionValues:
+			addf("services[%d] (%s) has %d host and path patterns, more than the %d a listener rule allows",
+				i, service.Name, patterns, components.MaxServiceConditionValues)
+		}
+		if service.Priority < 1 || service.Priority > 50000 {
+			addf("services[%d] (%s): priority %d must be between 1 and 50000", i, service.Name, service.Priority)
+		} else if other, ok := priorities[service.Priority]; ok {
+			addf("services[%d] (%s): priority %d is already used by %s", i, service.Name, service.Priority, other)
+		}
+		priorities[service.Priority] = service.Name
+	}
+
+	validateScaling(n.Scaling, addf)
+	if !instanceTypePattern.MatchString(n.AppInstanceType) {
+		addf("appInstanceType %q is not an EC2 instance type such as t3.micro", n.AppInstanceType)
+	}
+	switch {
+	case n.MinSize < 0:
+		addf("minSize %d must not be negative", n.MinSize)
+	case n.MaxSize < 1:
+		addf("maxSize %d must be at least 1", n.MaxSize)
+	case n.MinSize > n.MaxSize:
+		addf("minSize %d exceeds maxSize %d", n.MinSize, n.MaxSize)
+	case n.DesiredCapacity < n.MinSize || n.DesiredCapacity > n.MaxSize:
+		addf("desiredCapacity %d must be between minSize %d and maxSize %d", n.DesiredCapacity, n.MinSize, n.MaxSize)
+	}
+	validateInstanceRefresh(n.InstanceRefresh, addf)
+	if mixed := n.MixedInstances; mixed != nil {
+		if len(mixed.InstanceTypes) == 0 {
+			addf("mixedInstances.instanceTypes must list at least one instance type")
+		}
+		seen := map[string]bool{}
+		for i, instanceType := range mixed.InstanceTypes {
+			if !instanceTypePattern.MatchString(instanceType) {
+				addf("mixedInstances.instanceTypes[%d] %q is not an EC2 instance type", i, instanceType)
+			} else if seen[instanceType] {
+				addf("mixedInstances.instanceTypes lists %s more than once", instanceType)
+			}
+			seen[instanceType] = true
+		}
+		if mixed.OnDemandBaseCapacity < 0 {
+			addf("mixedInstances.onDemandBaseCapacity %d must not be negative", mixed.OnDemandBaseCapacity)
+		}
+		if mixed.OnDemandPercentageAboveBase < 0 || mixed.OnDemandPercentageAboveBase > 100 {
+			addf("mixedInstances.onDemandPercentageAboveBase %d must be between 0 and 100", mixed.OnDemandPercentageAboveBase)
+		}
+		if !slices.Contains(components.SpotAllocationStrategies, mixed.SpotAllocationStrategy) {
+			addf("mixedInstances.spotAllocationStrategy %q must be one of %s",
+				mixed.SpotAllocationStrategy, strings.Join(components.SpotAllocationStrategies, ", "))
+		}
+	}
+
+	validateDeployment(n.Deployment, addf)
+	if n.Deployment.BlueGreen() && len(n.Services) > 0 {
+		addf("services cannot be routed in %s deployments", components.DeploymentBlueGreen)
+	}
+
+	if n.CloseHttpPort && n.HttpRedirect {
+		addf("closeHttpPort needs httpRedirect set to false, as the redirect is served on port 80")
+	}
+
+	// A lookup name given by the older amiName key is reported under it
+	amiNameKey := "ami.name"
+	if n.AmiName != "" && n.Ami.Name == n.AmiName {
+		amiNameKey = "amiName"
+	}
+	validateAmi(n.Ami, amiNameKey, addf)
+	c.validateRegions(available, home, addf)
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// healthCheckMatcherPattern matches HTTP codes and ranges such as "200,301-302".
+var healthCheckMatcherPattern = regexp.MustCompile(`^\d{3}(-\d{3})?(,\d{3}(-\d{3})?)*$`)
+
+// validateTargetGroup checks target group settings against the limits ELB
+// enforces, reporting problems under key.
+func validateTargetGroup(key string, s components.TargetGroupSettings, addf func(string, ...interface{})) {
+	if !strings.HasPrefix(s.HealthCheckPath, "/") {
+		addf("%s: healthCheckPath %q must start with /", key, s.HealthCheckPath)
+	}
+	if s.HealthCheckInterval < 5 || s.HealthCheckInterval > 300 {
+		addf("%s: healthCheckInterval %d must be between 5 and 300 seconds", key, s.HealthCheckInterval)
+	}
+	if s.HealthCheckTimeout < 2 || s.HealthCheckTimeout > 120 {
+		addf("%s: healthCheckTimeout %d must be between 2 and 120 seconds", key, s.HealthCheckTimeout)
+	} else if s.HealthCheckTimeout >= s.HealthCheckInterval {
+		addf("%s: healthCheckTimeout %d must be shorter than healthCheckInterval %d", key, s.HealthCheckTimeout, s.HealthCheckInterval)
+	}
+	if !healthCheckMatcherPattern.MatchString(s.HealthCheckMatcher) {
+		addf("%s: healthCheckMatcher %q must list HTTP codes or ranges, e.g. 200,301-302", key, s.HealthCheckMatcher)
+	}
+	if s.HealthyThreshold < 2 || s.HealthyThreshold > 10 {
+		addf("%s: healthyThreshold %d must be between 2 and 10", key, s.HealthyThreshold)
+	}
+	if s.UnhealthyThreshold < 2 || s.UnhealthyThreshold > 10 {
+		addf("%s: unhealthyThreshold %d must be between 2 and 10", key, s.UnhealthyThreshold)
+	}
+	if s.SlowStart != 0 && (s.SlowStart < 30 || s.SlowStart > 900) {
+		addf("%s: slowStart %d must be 0 or between 30 and 900 seconds", key, s.SlowStart)
+	}
+	if d := s.DeregistrationDelay; d != nil && (*d < 0 || *d > 3600) {
+		addf("%s: deregistrationDelay %d must be between 0 and 3600 seconds", key, *d)
+	}
+	if s.StickinessDuration < 0 || s.StickinessDuration > 604800 {
+		addf("%s: stickinessDuration %d must be between 0 (off) and 604800 seconds", key, s.StickinessDuration)
+	}
+}
+
+// validateScaling checks the scaling policy and scheduled actions.
+func validateScaling(s components.ScalingSettings, addf func(string, ...interface{})) {
+	switch s.Policy {
+	case components.ScalingPolicySimple:
+	case components.ScalingPolicyTargetCpu:
+		if s.TargetValue <= 0 || s.TargetValue > 100 {
+			addf("scaling.targetValue %v must be a CPU percentage above 0 and at most 100", s.TargetValue)
+		}
+	case components.ScalingPolicyTargetRequests:
+		if s.TargetValue <= 0 {
+			addf("scaling.targetValue %v must be a positive number of requests per instance", s.TargetValue)
+		}
+	case components.ScalingPolicyStep:
+		if _, _, err := components.SplitScalingSteps(s.Steps); err != nil {
+			addf("scaling.steps: %v", err)
+		}
+		for i, step := range s.Steps {
+			for _, bound := range []*float64{step.From, step.To} {
+				if bound != nil && (*bound < 0 || *bound > 100) {
+					addf("scaling.steps[%d]: CPU bound %v must be between 0 and 100", i, *bound)
+				}
+			}
+		}
+	default:
+		addf("scaling.policy %q must be one of simple, targetCpu, targetRequests or step", s.Policy)
+	}
+	if s.Policy != components.ScalingPolicyStep && len(s.Steps) > 0 {
+		addf("scaling.steps are only used by the step policy")
+	}
+
+	names := map[string]bool{}
+	for i, schedule := range s.Schedules {
+		if !scheduleNamePattern.MatchString(schedule.Name) {
+			addf("scaling.schedules[%d].name %q must be letters, digits, dashes or underscores", i, schedule.Name)
+		} else if names[schedule.Name] {
+			addf("scaling.schedules[%d].name %q is used by another schedule", i, schedule.Name)
+		}
+		names[schedule.Name] = true
+		if len(strings.Fields(schedule.Recurrence)) != 5 {
+			addf("scaling.schedules[%d] (%s): recurrence %q must be a 5-field cron expression", i, schedule.Name, schedule.Recurrence)
+		}
+		if schedule.MinSize == nil && schedule.MaxSize == nil && schedule.DesiredCapacity == nil {
+			addf("scaling.schedules[%d] (%s) must set minSize, maxSize or desiredCapacity", i, schedule.Name)
+		}
+		for _, size := range []*int{schedule.MinSize, schedule.MaxSize, schedule.DesiredCapacity} {
+			if size != nil && *size < 0 {
+				addf("scaling.schedules[%d] (%s): sizes must not be negative", i, schedule.Name)
+				break
+			}
+		}
+		if schedule.MinSize != nil && schedule.MaxSize != nil && *schedule.MinSize > *schedule.MaxSize {
+			addf("scaling.schedules[%d] (%s): minSize %d exceeds maxSize %d", i, schedule.Name, *schedule.MinSize, *schedule.MaxSize)
+		}
+	}
+}
+
+// validateInstanceRefresh checks the rolling refresh preferences.
+func validateInstanceRefresh(r components.InstanceRefreshSettings, addf func(string, ...interface{})) {
+	r = r.WithDefaults()
+	if *r.MinHealthyPercentage < 0 || *r.MinHealthyPercentage > 100 {
+		addf("instanceRefresh.minHealthyPercentage %d must be between 0 and 100", *r.MinHealthyPercentage)
+	}
+	if *r.InstanceWarmup < 0 {
+		addf("instanceRefresh.instanceWarmup %d must not be negative", *r.InstanceWarmup)
+	}
+	for i, percentage := range r.CheckpointPercentages {
+		if percentage < 1 || percentage > 100 || (i > 0 && percentage <= r.CheckpointPercentages[i-1]) {
+			addf("instanceRefresh.checkpointPercentages must increase from 1 to 100, got %v", r.CheckpointPercentages)
+			break
+		}
+	}
+	if n := len(r.CheckpointPercentages); n > 0 && r.CheckpointPercentages[n-1] != 100 {
+		addf("instanceRefresh.checkpointPercentages must end at 100, got %v", r.CheckpointPercentages)
+	}
+	if d := r.CheckpointDelay; d != nil && (*d < 0 || *d > 172800) {
+		addf("instanceRefresh.checkpointDelay %d must be between 0 and 172800 seconds", *d)
+	}
+}
+
+// validateDeployment checks the deployment mode and the blue/green colors.
+func validateDeployment(d components.DeploymentSettings, addf func(string, ...interface{})) {
+	if d.Mode != components.DeploymentRolling && d.Mode != components.DeploymentBlueGreen {
+		addf("deployment.mode %q must be %s or %s", d.Mode, components.DeploymentRolling, components.DeploymentBlueGreen)
+	}
+	if !slices.Contains(components.Colors, d.ActiveColor) {
+		addf("deployment.activeColor %q must be one of %s", d.ActiveColor, strings.Join(components.Colors, ", "))
+	}
+	var colors []string
+	for color := range d.AmiIds {
+		colors = append(colors, color)
+	}
+	sort.Strings(colors)
+	for _, color := range colors {
+		if !slices.Contains(components.Colors, color) {
+			addf("deployment.amiIds: %q is not one of %s", color, strings.Join(components.Colors, ", "))
+		} else if !amiIdPattern.MatchString(d.AmiIds[color]) {
+			addf("deployment.amiIds.%s %q is not an AMI ID", color, d.AmiIds[color])
+		}
+	}
+	if d.BlueGreen() && slices.Contains(components.Colors, d.ActiveColor) && d.AmiIds[d.ActiveColor] == "" {
+		addf("deployment.amiIds.%s must pin the active color's AMI, so a new AMI only reaches the idle color", d.ActiveColor)
+	}
+}
+
+// validateAmi checks that the AMI strategy has the settings it needs.
+func validateAmi(a components.AmiSettings, nameKey string, addf func(string, ...interface{})) {
+	switch a.Strategy {
+	case components.AmiStrategyLookup:
+		if !amiNamePattern.MatchString(a.Name) {
+			addf("%s %q must be 3-128 characters of letters, digits, spaces, wildcards or ()[]./-'@_", nameKey, a.Name)
+		}
+		var keys []string
+		for key := range a.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if strings.TrimSpace(key) == "" || a.Tags[key] == "" {
+				addf("ami.tags: %q=%q needs a key and a value", key, a.Tags[key])
+			}
+		}
+	case components.AmiStrategyId:
+		if !amiIdPattern.MatchString(a.Id) {
+			addf("ami.id %q is not an AMI ID", a.Id)
+		}
+	case components.AmiStrategySsm:
+		if !ssmParameterPattern.MatchString(a.SsmParameter) {
+			addf("ami.ssmParameter %q must be an SSM parameter path starting with /", a.SsmParameter)
+		}
+	default:
+		addf("ami.strategy %q must be one of %s", a.Strategy, strings.Join(components.AmiStrategies, ", "))
+	}
+	for i, owner := range a.Owners {
+		if !amiOwnerPattern.MatchString(owner) {
+			addf("ami.owners[%d] %q must be an account ID, self, amazon or aws-marketplace", i, owner)
+		}
+	}
+	if a.MaxAgeDays < 0 {
+		addf("ami.maxAgeDays %d must not be negative", a.MaxAgeDays)
+	}
+}
+
+// validateRegions checks the further regions of a multi-region stack and
+// how DNS routes between them.
+func (c Config) validateRegions(available []string, home string, addf func(string, ...interface{})) {
+	n := c.Network
+	if len(n.Regions) == 0 {
+		return
+	}
+	seen := map[string]bool{home: true}
+	for i, region := range n.Regions {
+		key := fmt.Sprintf("regions[%d] (%s)", i, region.Region)
+		switch {
+		case !regionPattern.MatchString(region.Region):
+			addf("regions[%d]: %q is not an AWS region", i, region.Region)
+		case region.Region == home:
+			addf("%s is the stack's own region, which is always deployed", key)
+		case seen[region.Region]:
+			addf("%s is listed more than once", key)
+		case len(elbNamePrefix(region.Region)) > maxElbNamePrefix:
+			addf("%s: load balancer name prefix %q is longer than the %d characters ELB allows",
+				key, elbNamePrefix(region.Region), maxElbNamePrefix)
+		}
+		seen[region.Region] = true
+
+		vpcNet, err := netaddr.ParseIPv4Net(region.CidrBlockAddr)
+		switch {
+		case region.CidrBlockAddr == "":
+			// Inherited from the missing cidrBlockAddr, which is reported
+		case err != nil || vpcNet.String() != region.CidrBlockAddr:
+			addf("%s.cidrBlockAddr %q is not a valid IPv4 network address", key, region.CidrBlockAddr)
+		default:
+			if _, err := components.PlanSubnets(region.CidrBlockAddr, c.zones(available), n.SubnetTiers); err != nil {
+				addf("%s.cidrBlockAddr: %v", key, err)
+			}
+		}
+
+		switch {
+		case n.CreateCertificate && region.CertificateArn != "":
+			addf("%s.certificateArn must not be set with createCertificate", key)
+		case !n.CreateCertificate && region.CertificateArn == "":
+			addf("%s.certificateArn must be set to a certificate in the region, or createCertificate enabled", key)
+		}
+		for j, arn := range append([]string{region.CertificateArn}, region.AdditionalCertificateArns...) {
+			if arn == "" && j == 0 {
+				continue
+			}
+			if parts := strings.Split(arn, ":"); len(parts) < 6 || !strings.HasPrefix(arn, "arn:aws:acm:") || parts[3] != region.Region {
+				addf("%s: %q is not an ACM certificate ARN in %s", key, arn, region.Region)
+			}
+		}
+	}
+
+	switch n.DnsRouting {
+	case components.RoutingLatency:
+	case components.RoutingFailover:
+		if len(n.Regions) != 1 {
+			addf("dnsRouting %s fails over from the stack's region to exactly one other, got %d regions",
+				components.RoutingFailover, len(n.Regions))
+		}
+	default:
+		addf("dnsRouting %q must be %s or %s", n.DnsRouting, components.RoutingLatency, components.RoutingFailover)
+	}
+}